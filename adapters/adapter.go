@@ -57,6 +57,8 @@ type Adapter interface {
 
 	ExecuteScripts(method, sql string, values []interface{}) (sc Scanner)
 	ExecuteScriptsCtx(ctx context.Context, method, sql string, values []interface{}) (sc Scanner)
+	// ExplainCostCtx returns the planner estimated total cost of SQL
+	ExplainCostCtx(ctx context.Context, SQL string, params ...interface{}) (cost float64, err error)
 
 	FieldsPermissions(r *http.Request, table string, op string, userName string) (fields []string, err error)
 	GetScript(verb, folder, scriptName string) (script string, err error)
@@ -68,6 +70,7 @@ type Adapter interface {
 	InsertWithTransaction(tx *sql.Tx, SQL string, params ...interface{}) (sc Scanner)
 	InsertSQL(database string, schema string, table string, names string, placeholders string) string
 	JoinByRequest(r *http.Request) (values []string, err error)
+	OnConflictByRequest(r *http.Request, names string) (conflictSyntax string, doNothing bool, err error)
 	OrderByRequest(r *http.Request) (values string, err error)
 	PaginateIfPossible(r *http.Request) (paginatedQuery string, err error)
 	ParseBatchInsertRequest(r *http.Request) (colsName string, colsValue string, values []interface{}, err error)
@@ -148,6 +148,11 @@ func (m *Mock) ExecuteScriptsCtx(ctx context.Context, method string, sql string,
 	return
 }
 
+// ExplainCostCtx mock
+func (m *Mock) ExplainCostCtx(ctx context.Context, SQL string, params ...interface{}) (cost float64, err error) {
+	return
+}
+
 // WhereByRequest mock
 func (m *Mock) WhereByRequest(r *http.Request, initialPlaceholderID int) (whereSyntax string, values []interface{}, err error) {
 	return
@@ -168,6 +173,11 @@ func (m *Mock) DatabaseClause(req *http.Request) (query string, hasCount bool) {
 	return
 }
 
+// OnConflictByRequest mock
+func (m *Mock) OnConflictByRequest(r *http.Request, names string) (conflictSyntax string, doNothing bool, err error) {
+	return
+}
+
 // OrderByRequest mock
 func (m *Mock) OrderByRequest(r *http.Request) (values string, err error) {
 	return
@@ -285,6 +285,26 @@ func (mr *MockAdapterMockRecorder) ExecuteScriptsCtx(ctx, method, sql, values in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteScriptsCtx", reflect.TypeOf((*MockAdapter)(nil).ExecuteScriptsCtx), ctx, method, sql, values)
 }
 
+// ExplainCostCtx mocks base method.
+func (m *MockAdapter) ExplainCostCtx(ctx context.Context, SQL string, params ...interface{}) (float64, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, SQL}
+	for _, a := range params {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExplainCostCtx", varargs...)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExplainCostCtx indicates an expected call of ExplainCostCtx.
+func (mr *MockAdapterMockRecorder) ExplainCostCtx(ctx, SQL interface{}, params ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, SQL}, params...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExplainCostCtx", reflect.TypeOf((*MockAdapter)(nil).ExplainCostCtx), varargs...)
+}
+
 // FieldsPermissions mocks base method.
 func (m *MockAdapter) FieldsPermissions(r *http.Request, table, op string, userName string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -459,6 +479,22 @@ func (mr *MockAdapterMockRecorder) JoinByRequest(r interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinByRequest", reflect.TypeOf((*MockAdapter)(nil).JoinByRequest), r)
 }
 
+// OnConflictByRequest mocks base method.
+func (m *MockAdapter) OnConflictByRequest(r *http.Request, names string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OnConflictByRequest", r, names)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// OnConflictByRequest indicates an expected call of OnConflictByRequest.
+func (mr *MockAdapterMockRecorder) OnConflictByRequest(r, names interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConflictByRequest", reflect.TypeOf((*MockAdapter)(nil).OnConflictByRequest), r, names)
+}
+
 // OrderByRequest mocks base method.
 func (m *MockAdapter) OrderByRequest(r *http.Request) (string, error) {
 	m.ctrl.T.Helper()
@@ -1,6 +1,8 @@
 package postgres
 
 import (
+	"database/sql"
+
 	"github.com/prest/prest/v2/adapters/postgres/internal/connection"
 
 	"github.com/jmoiron/sqlx"
@@ -26,6 +28,11 @@ func AddDatabaseToPool(name string) (*sqlx.DB, error) {
 	return connection.AddDatabaseToPool(name)
 }
 
+// PoolStats connection stats summed over all pooled databases and replicas
+func PoolStats() sql.DBStats {
+	return connection.Stats()
+}
+
 // MustGet get postgres connection
 func MustGet() *sqlx.DB {
 	return connection.MustGet()
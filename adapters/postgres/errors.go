@@ -9,8 +9,11 @@ var (
 	ErrMustSelectOneField      = errors.New("you must select at least one field")
 	ErrNoTableName             = errors.New("unable to find table name")
 	ErrInvalidOperator         = errors.New("invalid operator")
+	ErrInvalidConflictAction   = errors.New("invalid conflict action")
 	ErrInvalidGroupFn          = errors.New("invalid group function")
 	// ErrBodyEmpty err throw when body is empty
 	ErrBodyEmpty           = errors.New("body is empty")
 	ErrEmptyOrInvalidSlice = errors.New("empty or invalid slice")
+	// ErrBatchTooLarge err throw when a batch has more rows than config.PrestConf.BatchMaxSize
+	ErrBatchTooLarge = errors.New("batch too large")
 )
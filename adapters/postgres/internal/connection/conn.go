@@ -1,6 +1,7 @@
 package connection
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -118,6 +119,31 @@ func AddDatabaseToPool(name string) (*sqlx.DB, error) {
 	return DB, nil
 }
 
+// Stats sums the connection stats of every database in the pool and of the
+// read replicas
+func Stats() (stats sql.DBStats) {
+	p := GetPool()
+
+	p.Mtx.Lock()
+	for _, DB := range p.DB {
+		addStats(&stats, DB.Stats())
+	}
+	p.Mtx.Unlock()
+	for _, DB := range replicaPools() {
+		addStats(&stats, DB.Stats())
+	}
+	return
+}
+
+func addStats(stats *sql.DBStats, s sql.DBStats) {
+	stats.MaxOpenConnections += s.MaxOpenConnections
+	stats.OpenConnections += s.OpenConnections
+	stats.InUse += s.InUse
+	stats.Idle += s.Idle
+	stats.WaitCount += s.WaitCount
+	stats.WaitDuration += s.WaitDuration
+}
+
 // MustGet get postgres connection
 func MustGet() *sqlx.DB {
 	var err error
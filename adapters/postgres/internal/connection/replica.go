@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prest/prest/v2/config"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	replicaOnce sync.Once
+	replicas    []*sqlx.DB
+	replicaNext atomic.Uint64
+
+	// dsnPassword matches the password of a key/value connection string
+	dsnPassword = regexp.MustCompile(`password=('(\\.|[^'])*'|\S*)`)
+	// urlUserInfo matches the user info of a URL that can't be parsed
+	urlUserInfo = regexp.MustCompile(`://[^@/]*@`)
+)
+
+// openReplicas opens a pool per configured replica URL. The URL is parsed
+// up front but nothing is dialed, connections are made and retried by
+// database/sql on use. A URL that doesn't parse is logged and skipped, so
+// its share of the reads goes to the other replicas or to the primary
+func openReplicas() {
+	for _, dsn := range config.PrestConf.PGReplicaURLs {
+		connector, err := pq.NewConnector(dsn)
+		if err != nil {
+			// parse errors quote the URL, password included
+			slog.Error("could not open read replica, skipping it", "url", redactURL(dsn), "err", redactURL(err.Error()))
+			continue
+		}
+		DB := sqlx.NewDb(sql.OpenDB(connector), "postgres")
+		DB.SetMaxIdleConns(config.PrestConf.PGMaxIdleConn)
+		DB.SetMaxOpenConns(config.PrestConf.PGMaxOpenConn)
+		replicas = append(replicas, DB)
+	}
+}
+
+// redactURL hides the password of a connection URL or key/value string
+func redactURL(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	dsn = urlUserInfo.ReplaceAllString(dsn, "://xxxxx@")
+	return dsnPassword.ReplaceAllString(dsn, "password=xxxxx")
+}
+
+// replicaPools returns the pools of the read replicas
+func replicaPools() []*sqlx.DB {
+	replicaOnce.Do(openReplicas)
+	return replicas
+}
+
+// GetReplica returns the next read replica in round-robin order, or nil
+// when no replica is configured
+func GetReplica() *sqlx.DB {
+	pools := replicaPools()
+	if len(pools) == 0 {
+		return nil
+	}
+	return pools[(replicaNext.Add(1)-1)%uint64(len(pools))]
+}
+
+// IsReplica tells whether DB is one of the read replicas
+func IsReplica(DB *sqlx.DB) bool {
+	for _, r := range replicas {
+		if r == DB {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Notification is a NOTIFY payload received on a LISTEN channel
+type Notification struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+}
+
+// Subscription receives the notifications of its channels, a slow reader
+// misses the notifications that don't fit its buffer
+type Subscription struct {
+	C        <-chan Notification
+	c        chan Notification
+	channels []string
+	once     sync.Once
+}
+
+// Close stops the subscription and closes C
+func (s *Subscription) Close() {
+	s.once.Do(func() { hub.unsubscribe(s) })
+}
+
+type notifyHub struct {
+	mtx      sync.Mutex
+	listener *pq.Listener
+	subs     map[string]map[*Subscription]struct{}
+}
+
+var hub = &notifyHub{subs: make(map[string]map[*Subscription]struct{})}
+
+// Subscribe listens to channels on the default database, the connection is
+// shared by every subscription and reconnects on failure
+func Subscribe(channels []string, buffer int) (*Subscription, error) {
+	c := make(chan Notification, buffer)
+	s := &Subscription{C: c, c: c, channels: channels}
+	if err := hub.subscribe(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (h *notifyHub) subscribe(s *Subscription) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.listener == nil {
+		h.listener = pq.NewListener(GetURI(GetDatabase()), time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				slog.Warn("listener connection", "event", ev, "err", err)
+			}
+		})
+		go h.dispatch(h.listener.Notify)
+	}
+	for i, channel := range s.channels {
+		if len(h.subs[channel]) == 0 {
+			if err := h.listener.Listen(channel); err != nil && err != pq.ErrChannelAlreadyOpen {
+				for _, done := range s.channels[:i] {
+					h.remove(s, done)
+				}
+				return err
+			}
+			h.subs[channel] = make(map[*Subscription]struct{})
+		}
+		h.subs[channel][s] = struct{}{}
+	}
+	return nil
+}
+
+func (h *notifyHub) unsubscribe(s *Subscription) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for _, channel := range s.channels {
+		h.remove(s, channel)
+	}
+	close(s.c)
+}
+
+// remove drops s from channel and stops listening to channel without
+// subscribers, h.mtx must be held
+func (h *notifyHub) remove(s *Subscription, channel string) {
+	delete(h.subs[channel], s)
+	if len(h.subs[channel]) > 0 {
+		return
+	}
+	delete(h.subs, channel)
+	if err := h.listener.Unlisten(channel); err != nil {
+		slog.Warn("could not unlisten", "channel", channel, "err", err)
+	}
+}
+
+// dispatch fans the notifications out without blocking on slow subscribers
+func (h *notifyHub) dispatch(notify <-chan *pq.Notification) {
+	for n := range notify {
+		// nil is sent after a reconnection, notifications may have been lost
+		if n == nil {
+			continue
+		}
+		h.mtx.Lock()
+		for s := range h.subs[n.Channel] {
+			select {
+			case s.c <- Notification{Channel: n.Channel, Payload: n.Extra}:
+			default:
+				slog.Warn("subscriber too slow, notification dropped", "channel", n.Channel)
+			}
+		}
+		h.mtx.Unlock()
+	}
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"bytes"
+	"container/list"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -52,21 +53,43 @@ var groupRegex *regexp.Regexp
 
 var stmts *Stmt
 
-// Stmt statement representation
+// ddlRegex matches statements that change the schema, after them the cached
+// statements may no longer match the tables they were planned against
+var ddlRegex *regexp.Regexp
+
+// Stmt statement representation, a LRU cache of prepared statements keyed by
+// the rendered SQL and bounded by config.PrestConf.PGCacheSize
 type Stmt struct {
 	Mtx        *sync.Mutex
-	PrepareMap map[string]*sql.Stmt
-}
-
-// Prepare statement
-func (s *Stmt) Prepare(db *sqlx.DB, tx *sql.Tx, SQL string) (statement *sql.Stmt, err error) {
-	if config.PrestConf.PGCache && (tx == nil) {
-		var exists bool
+	PrepareMap map[string]*cachedStmt
+	lru        *list.List
+	elements   map[string]*list.Element
+}
+
+// cachedStmt counts the requests using a cached statement, once evicted it
+// is closed when the last of them releases it
+type cachedStmt struct {
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// Prepare statement, release must be called once the statement is no longer
+// used: it closes the statements that are not cached and lets the cache close
+// the evicted ones
+func (s *Stmt) Prepare(db *sqlx.DB, tx *sql.Tx, SQL string) (statement *sql.Stmt, release func(), err error) {
+	// the cache is keyed by SQL only, statements of replicas are not cached
+	cache := config.PrestConf.PGCache && tx == nil && !connection.IsReplica(db) && !isDDL(SQL)
+	if cache {
 		s.Mtx.Lock()
-		statement, exists = s.PrepareMap[SQL]
+		cached, exists := s.PrepareMap[SQL]
+		if exists {
+			s.lru.MoveToFront(s.elements[SQL])
+			cached.refs++
+		}
 		s.Mtx.Unlock()
 		if exists {
-			return
+			return cached.stmt, s.releaser(cached), nil
 		}
 	}
 
@@ -79,12 +102,84 @@ func (s *Stmt) Prepare(db *sqlx.DB, tx *sql.Tx, SQL string) (statement *sql.Stmt
 	if err != nil {
 		return
 	}
-	if config.PrestConf.PGCache && (tx == nil) {
-		s.Mtx.Lock()
-		s.PrepareMap[SQL] = statement
-		s.Mtx.Unlock()
+	if !cache {
+		return statement, func() { statement.Close() }, nil
+	}
+	cached := s.add(SQL, statement)
+	return cached.stmt, s.releaser(cached), nil
+}
+
+// add caches statement under SQL for the calling request, dropping the least
+// recently used statements past the cache size
+func (s *Stmt) add(SQL string, statement *sql.Stmt) *cachedStmt {
+	s.Mtx.Lock()
+	defer s.Mtx.Unlock()
+	if cached, ok := s.PrepareMap[SQL]; ok {
+		// another request prepared the same SQL meanwhile, nobody uses ours yet
+		statement.Close()
+		s.lru.MoveToFront(s.elements[SQL])
+		cached.refs++
+		return cached
+	}
+	cached := &cachedStmt{stmt: statement, refs: 1}
+	s.PrepareMap[SQL] = cached
+	s.elements[SQL] = s.lru.PushFront(SQL)
+	for config.PrestConf.PGCacheSize > 0 && s.lru.Len() > config.PrestConf.PGCacheSize {
+		key := s.lru.Remove(s.lru.Back()).(string)
+		delete(s.elements, key)
+		s.drop(key)
+	}
+	return cached
+}
+
+// drop removes the statement cached under key, closing it unless requests
+// still use it. The caller holds Mtx
+func (s *Stmt) drop(key string) {
+	cached := s.PrepareMap[key]
+	delete(s.PrepareMap, key)
+	cached.evicted = true
+	if cached.refs == 0 {
+		cached.stmt.Close()
+	}
+}
+
+// releaser returns the release func of a request using cached
+func (s *Stmt) releaser(cached *cachedStmt) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.Mtx.Lock()
+			defer s.Mtx.Unlock()
+			cached.refs--
+			if cached.evicted && cached.refs == 0 {
+				cached.stmt.Close()
+			}
+		})
 	}
-	return
+}
+
+// Len returns the number of cached statements
+func (s *Stmt) Len() int {
+	s.Mtx.Lock()
+	defer s.Mtx.Unlock()
+	return s.lru.Len()
+}
+
+// Evict drops every cached statement, the ones in use are closed once
+// released
+func (s *Stmt) Evict() {
+	s.Mtx.Lock()
+	defer s.Mtx.Unlock()
+	for key := range s.PrepareMap {
+		s.drop(key)
+	}
+	s.lru.Init()
+	clear(s.elements)
+}
+
+// isDDL return true if SQL changes the schema
+func isDDL(SQL string) bool {
+	return ddlRegex.MatchString(SQL)
 }
 
 // Load postgres
@@ -112,6 +207,7 @@ func init() {
 	insertTableNameRegex = regexp.MustCompile(`(?i)INTO\s+([\w|\.|-]*\.)*([\w|-]+)\s*\(`)
 	insertTableNameQuotesRegex = regexp.MustCompile(`(?i)INTO\s+([\w|\.|"|-]*\.)*"([\w|-]+)"\s*\(`)
 	groupRegex = regexp.MustCompile(`\"(.+?)\"`)
+	ddlRegex = regexp.MustCompile(`(?i)^\s*(CREATE|ALTER|DROP|TRUNCATE|COMMENT|GRANT|REVOKE)\b`)
 }
 
 // GetStmt get statement
@@ -119,7 +215,9 @@ func GetStmt() *Stmt {
 	if stmts == nil {
 		stmts = &Stmt{
 			Mtx:        &sync.Mutex{},
-			PrepareMap: make(map[string]*sql.Stmt),
+			PrepareMap: make(map[string]*cachedStmt),
+			lru:        list.New(),
+			elements:   make(map[string]*list.Element),
 		}
 	}
 	return stmts
@@ -153,13 +251,13 @@ func (adapter *Postgres) GetTransactionCtx(ctx context.Context) (tx *sql.Tx, err
 	return db.Begin()
 }
 
-// Prepare statement func
-func Prepare(db *sqlx.DB, SQL string) (stmt *sql.Stmt, err error) {
+// Prepare statement func, call release once done with stmt
+func Prepare(db *sqlx.DB, SQL string) (stmt *sql.Stmt, release func(), err error) {
 	return GetStmt().Prepare(db, nil, SQL)
 }
 
-// PrepareTx statement func
-func PrepareTx(tx *sql.Tx, SQL string) (stmt *sql.Stmt, err error) {
+// PrepareTx statement func, call release once done with stmt
+func PrepareTx(tx *sql.Tx, SQL string) (stmt *sql.Stmt, release func(), err error) {
 	return GetStmt().Prepare(nil, tx, SQL)
 }
 
@@ -209,7 +307,9 @@ func chkInvalidIdentifier(identifer ...string) bool {
 func (adapter *Postgres) WhereByRequest(r *http.Request, initialPlaceholderID int) (whereSyntax string, values []interface{}, err error) {
 	whereKey := []string{}
 	whereValues := []string{}
-	var value, op string
+	// cast is appended to the placeholder of the value, $contains casts it to
+	// jsonb so @> isn't taken for another type's containment
+	var value, op, cast string
 
 	pid := initialPlaceholderID
 	for key, val := range r.URL.Query() {
@@ -224,8 +324,11 @@ func (adapter *Postgres) WhereByRequest(r *http.Request, initialPlaceholderID in
 						op = "$eq"
 					}
 					value = removeOperatorRegex.ReplaceAllString(v, "")
-					op, err = GetQueryOperator(op)
-					if err != nil {
+					cast = ""
+					if op == "$contains" {
+						// filters only, joins and having don't take it
+						op, cast = "@>", "::jsonb"
+					} else if op, err = GetQueryOperator(op); err != nil {
 						return
 					}
 				}
@@ -245,7 +348,7 @@ func (adapter *Postgres) WhereByRequest(r *http.Request, initialPlaceholderID in
 						// escape single quotes in json attribute key
 						safeAttr := strings.ReplaceAll(jsonField[1], "'", "''")
 						whereKey = append(whereKey, fmt.Sprintf(`%s->>'%s' %s $%d`, jsonField[0], safeAttr, op, pid))
-						values = append(values, value)
+						whereValues = append(whereValues, value)
 					case "tsquery":
 						tsQueryField := strings.Split(keyInfo[0], "$")
 						if !ident.IsValid(tsQueryField[0]) {
@@ -273,15 +376,26 @@ func (adapter *Postgres) WhereByRequest(r *http.Request, initialPlaceholderID in
 					continue
 				}
 
-				if !ident.IsValid(rawKey) {
-					err = errors.Wrapf(ErrInvalidIdentifier, "%s", rawKey)
-					return
+				var quotedKey string
+				if strings.Contains(rawKey, "->") {
+					// json path, the keys are bound as parameters
+					var keys []string
+					quotedKey, keys, err = jsonPath(rawKey, pid)
+					if err != nil {
+						return
+					}
+					whereValues = append(whereValues, keys...)
+					pid += len(keys)
+				} else {
+					if !ident.IsValid(rawKey) {
+						err = errors.Wrapf(ErrInvalidIdentifier, "%s", rawKey)
+						return
+					}
+					// always quote the field for SQL usage without mutating the original key
+					fields := strings.Split(rawKey, ".")
+					quotedKey = fmt.Sprintf(`"%s"`, strings.Join(fields, `"."`))
 				}
 
-				// always quote the field for SQL usage without mutating the original key
-				fields := strings.Split(rawKey, ".")
-				quotedKey := fmt.Sprintf(`"%s"`, strings.Join(fields, `"."`))
-
 				switch op {
 				case "IN", "NOT IN":
 					v := strings.Split(value, ",")
@@ -298,8 +412,8 @@ func (adapter *Postgres) WhereByRequest(r *http.Request, initialPlaceholderID in
 					pid++
 				case "IS NULL", "IS NOT NULL", "IS TRUE", "IS NOT TRUE", "IS FALSE", "IS NOT FALSE":
 					whereKey = append(whereKey, fmt.Sprintf(`%s %s`, quotedKey, op))
-				default: // "=", "!=", ">", ">=", "<", "<="
-					whereKey = append(whereKey, fmt.Sprintf(`%s %s $%d`, quotedKey, op, pid))
+				default: // "=", "!=", ">", ">=", "<", "<=", "@>"
+					whereKey = append(whereKey, fmt.Sprintf(`%s %s $%d%s`, quotedKey, op, pid, cast))
 					whereValues = append(whereValues, value)
 					pid++
 				}
@@ -321,6 +435,41 @@ func (adapter *Postgres) WhereByRequest(r *http.Request, initialPlaceholderID in
 	return
 }
 
+// jsonPath quotes the column of a json path filter key like data->a->>b into
+// "data"->$pid::text->>$pid+1::text, returning the keys to bind
+func jsonPath(rawKey string, pid int) (expr string, keys []string, err error) {
+	i := strings.Index(rawKey, "->")
+	column := rawKey[:i]
+	if !ident.IsValid(column) {
+		err = errors.Wrapf(ErrInvalidIdentifier, "%s", column)
+		return
+	}
+	expr = fmt.Sprintf(`"%s"`, strings.Join(strings.Split(column, "."), `"."`))
+	for rest := rawKey[i:]; rest != ""; {
+		op := "->"
+		if strings.HasPrefix(rest, "->>") {
+			op = "->>"
+		}
+		rest = rest[len(op):]
+		key := rest
+		if next := strings.Index(rest, "->"); next >= 0 {
+			key, rest = rest[:next], rest[next:]
+		} else {
+			rest = ""
+		}
+		if len(key) > 1 && key[0] == '\'' && key[len(key)-1] == '\'' {
+			key = key[1 : len(key)-1]
+		}
+		if key == "" {
+			err = errors.Wrapf(ErrInvalidIdentifier, "%s", rawKey)
+			return
+		}
+		expr += fmt.Sprintf(`%s$%d::text`, op, pid+len(keys))
+		keys = append(keys, key)
+	}
+	return
+}
+
 // ReturningByRequest create interface for queries + returning
 func (adapter *Postgres) ReturningByRequest(r *http.Request) (returningSyntax string, err error) {
 	// TODO: write documentation:
@@ -345,6 +494,54 @@ func (adapter *Postgres) ReturningByRequest(r *http.Request) (returningSyntax st
 	return
 }
 
+// OnConflictByRequest create the ON CONFLICT clause of an insert from the
+// _on_conflict (comma separated columns) and _do (update or nothing) query
+// parameters, names are the quoted columns being inserted. doNothing tells
+// that conflicting rows are skipped, so the insert may return no row
+func (adapter *Postgres) OnConflictByRequest(r *http.Request, names string) (conflictSyntax string, doNothing bool, err error) {
+	query := r.URL.Query()
+	onConflict := query.Get("_on_conflict")
+	if onConflict == "" {
+		return
+	}
+	conflictCols := make([]string, 0)
+	for _, col := range strings.Split(onConflict, ",") {
+		col = strings.TrimSpace(col)
+		quoted, quoteErr := ident.Quote(col)
+		if quoteErr != nil || !ident.IsSegment(col) {
+			err = errors.Wrap(ErrInvalidIdentifier, "OnConflict")
+			return
+		}
+		conflictCols = append(conflictCols, quoted)
+	}
+	conflictSyntax = fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(conflictCols, ", "))
+
+	switch strings.ToLower(query.Get("_do")) {
+	case "", "nothing":
+		conflictSyntax += " DO NOTHING"
+		doNothing = true
+	case "update":
+		set := make([]string, 0)
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if slices.Contains(conflictCols, name) {
+				continue
+			}
+			set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", name, name))
+		}
+		if len(set) == 0 {
+			conflictSyntax += " DO NOTHING"
+			doNothing = true
+			return
+		}
+		conflictSyntax += " DO UPDATE SET " + strings.Join(set, ", ")
+	default:
+		conflictSyntax = ""
+		err = ErrInvalidConflictAction
+	}
+	return
+}
+
 func sliceToJSONList(ifaceSlice interface{}) (returnValue string, err error) {
 	v := reflect.ValueOf(ifaceSlice)
 
@@ -433,6 +630,18 @@ func (adapter *Postgres) ParseBatchInsertRequest(r *http.Request) (colsName stri
 		err = ErrBodyEmpty
 		return
 	}
+	if max := config.PrestConf.BatchMaxSize; max > 0 && len(recordSet) > max {
+		err = errors.Wrapf(ErrBatchTooLarge, "%d rows, max %d", len(recordSet), max)
+		return
+	}
+	for _, record := range recordSet {
+		for key := range record {
+			if !ident.IsValid(key) {
+				err = errors.Wrap(ErrInvalidIdentifier, "BatchInsert")
+				return
+			}
+		}
+	}
 	recordKeys := adapter.tableKeys(recordSet[0])
 	colsName = strings.Join(recordKeys, ",")
 	values, placeholders, err = adapter.operationValues(recordSet, recordKeys)
@@ -696,18 +905,19 @@ func (adapter *Postgres) CountByRequest(req *http.Request) (countQuery string, e
 // allows setting timeout
 func (adapter *Postgres) QueryCtx(ctx context.Context, SQL string, params ...interface{}) (sc adapters.Scanner) {
 	// use the db_name that was set on request to avoid runtime collisions
-	db, err := getDBFromCtx(ctx)
+	db, err := getReadDBFromCtx(ctx)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
 	SQL = fmt.Sprintf("SELECT %s(s) FROM (%s) s", config.PrestConf.JSONAggType, SQL)
 	slog.Debug("generated SQL", "sql", SQL, "parameters", params)
-	p, err := Prepare(db, SQL)
+	p, release, err := Prepare(db, SQL)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	var jsonData []byte
 	err = p.QueryRowContext(ctx, params...).Scan(&jsonData)
 	if len(jsonData) == 0 {
@@ -720,6 +930,33 @@ func (adapter *Postgres) QueryCtx(ctx context.Context, SQL string, params ...int
 	}
 }
 
+// ExplainCostCtx asks the planner for the estimated total cost of SQL
+// without running it, on the same database QueryCtx would use
+func (adapter *Postgres) ExplainCostCtx(ctx context.Context, SQL string, params ...interface{}) (cost float64, err error) {
+	db, err := getReadDBFromCtx(ctx)
+	if err != nil {
+		return
+	}
+	var plan []byte
+	err = db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+SQL, params...).Scan(&plan)
+	if err != nil {
+		return
+	}
+	var explain []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+	}
+	if err = json.Unmarshal(plan, &explain); err != nil {
+		return
+	}
+	if len(explain) == 0 {
+		err = fmt.Errorf("empty plan")
+		return
+	}
+	return explain[0].Plan.TotalCost, nil
+}
+
 func (adapter *Postgres) Query(SQL string, params ...interface{}) (sc adapters.Scanner) {
 	db, err := connection.Get()
 	if err != nil {
@@ -728,10 +965,11 @@ func (adapter *Postgres) Query(SQL string, params ...interface{}) (sc adapters.S
 	}
 	SQL = fmt.Sprintf("SELECT %s(s) FROM (%s) s", config.PrestConf.JSONAggType, SQL)
 	slog.Debug("generated SQL", "sql", SQL, "parameters", params)
-	p, err := Prepare(db, SQL)
+	p, release, err := Prepare(db, SQL)
 	if err != nil {
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	var jsonData []byte
 	err = p.QueryRow(params...).Scan(&jsonData)
 	if len(jsonData) == 0 {
@@ -752,10 +990,11 @@ func (adapter *Postgres) QueryCount(SQL string, params ...interface{}) (sc adapt
 	}
 
 	slog.Debug("generated SQL", "sql", SQL, "parameters", params)
-	p, err := Prepare(db, SQL)
+	p, release, err := Prepare(db, SQL)
 	if err != nil {
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 
 	var result struct {
 		Count int64 `json:"count"`
@@ -775,17 +1014,18 @@ func (adapter *Postgres) QueryCount(SQL string, params ...interface{}) (sc adapt
 
 // QueryCount process queries with count
 func (adapter *Postgres) QueryCountCtx(ctx context.Context, SQL string, params ...interface{}) (sc adapters.Scanner) {
-	db, err := getDBFromCtx(ctx)
+	db, err := getReadDBFromCtx(ctx)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
 	slog.Debug("generated SQL", "sql", SQL, "parameters", params)
-	p, err := Prepare(db, SQL)
+	p, release, err := Prepare(db, SQL)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 
 	var result struct {
 		Count int64 `json:"count"`
@@ -964,11 +1204,12 @@ func (adapter *Postgres) BatchInsertValues(SQL string, values ...interface{}) (s
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
-	stmt, err := adapter.fullInsert(db, nil, SQL)
+	stmt, release, err := adapter.fullInsert(db, nil, SQL)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	jsonData := []byte("[")
 	rows, err := stmt.Query(values...)
 	if err != nil {
@@ -1007,11 +1248,12 @@ func (adapter *Postgres) BatchInsertValuesCtx(ctx context.Context, SQL string, v
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
-	stmt, err := adapter.fullInsert(db, nil, SQL)
+	stmt, release, err := adapter.fullInsert(db, nil, SQL)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	jsonData := []byte("[")
 	rows, err := stmt.Query(values...)
 	if err != nil {
@@ -1043,7 +1285,7 @@ func (adapter *Postgres) BatchInsertValuesCtx(ctx context.Context, SQL string, v
 	}
 }
 
-func (adapter *Postgres) fullInsert(db *sqlx.DB, tx *sql.Tx, SQL string) (stmt *sql.Stmt, err error) {
+func (adapter *Postgres) fullInsert(db *sqlx.DB, tx *sql.Tx, SQL string) (stmt *sql.Stmt, release func(), err error) {
 	tableName := insertTableNameQuotesRegex.FindStringSubmatch(SQL)
 	if len(tableName) < 2 {
 		tableName = insertTableNameRegex.FindStringSubmatch(SQL)
@@ -1054,9 +1296,9 @@ func (adapter *Postgres) fullInsert(db *sqlx.DB, tx *sql.Tx, SQL string) (stmt *
 	}
 	SQL = fmt.Sprintf(`%s RETURNING row_to_json("%s")`, SQL, tableName[2])
 	if tx != nil {
-		stmt, err = PrepareTx(tx, SQL)
+		stmt, release, err = PrepareTx(tx, SQL)
 	} else {
-		stmt, err = Prepare(db, SQL)
+		stmt, release, err = Prepare(db, SQL)
 	}
 	return
 }
@@ -1087,11 +1329,12 @@ func (adapter *Postgres) InsertWithTransaction(tx *sql.Tx, SQL string, params ..
 }
 
 func (adapter *Postgres) insert(db *sqlx.DB, tx *sql.Tx, SQL string, params ...interface{}) (sc adapters.Scanner) {
-	stmt, err := adapter.fullInsert(db, tx, SQL)
+	stmt, release, err := adapter.fullInsert(db, tx, SQL)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	slog.Debug("log details", "sql", SQL, "parameters", params)
 	var jsonData []byte
 	err = stmt.QueryRow(params...).Scan(&jsonData)
@@ -1129,16 +1372,18 @@ func (adapter *Postgres) DeleteWithTransaction(tx *sql.Tx, SQL string, params ..
 func (adapter *Postgres) delete(db *sqlx.DB, tx *sql.Tx, SQL string, params ...interface{}) (sc adapters.Scanner) {
 	slog.Debug("generated SQL", "sql", SQL, "parameters", params)
 	var stmt *sql.Stmt
+	var release func()
 	var err error
 	if tx != nil {
-		stmt, err = PrepareTx(tx, SQL)
+		stmt, release, err = PrepareTx(tx, SQL)
 	} else {
-		stmt, err = Prepare(db, SQL)
+		stmt, release, err = Prepare(db, SQL)
 	}
 	if err != nil {
 		slog.Error("could not prepare sql", "sql", SQL, "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	if strings.Contains(SQL, "RETURNING") {
 		rows, _ := stmt.Query(params...)
 		cols, _ := rows.Columns()
@@ -1220,16 +1465,18 @@ func (adapter *Postgres) UpdateWithTransaction(tx *sql.Tx, SQL string, params ..
 
 func (adapter *Postgres) update(db *sqlx.DB, tx *sql.Tx, SQL string, params ...interface{}) (sc adapters.Scanner) {
 	var stmt *sql.Stmt
+	var release func()
 	var err error
 	if tx != nil {
-		stmt, err = PrepareTx(tx, SQL)
+		stmt, release, err = PrepareTx(tx, SQL)
 	} else {
-		stmt, err = Prepare(db, SQL)
+		stmt, release, err = Prepare(db, SQL)
 	}
 	if err != nil {
 		slog.Error("could not prepare sql", "sql", SQL, "err", err)
 		return &scanner.PrestScanner{Error: err}
 	}
+	defer release()
 	slog.Debug("generated SQL", "sql", SQL, "parameters", params)
 	if strings.Contains(SQL, "RETURNING") {
 		rows, _ := stmt.Query(params...)
@@ -1792,6 +2039,19 @@ func (adapter *Postgres) GetDatabase() string {
 	return connection.GetDatabase()
 }
 
+// getReadDBFromCtx returns a read replica for queries on the default
+// database unless the request asked for the primary with _primary=true
+func getReadDBFromCtx(ctx context.Context) (db *sqlx.DB, err error) {
+	dbName, _ := ctx.Value(pctx.DBNameKey).(string)
+	primary, _ := ctx.Value(pctx.UsePrimaryKey).(bool)
+	if !primary && (dbName == "" || dbName == config.PrestConf.PGDatabase) {
+		if replica := connection.GetReplica(); replica != nil {
+			return replica, nil
+		}
+	}
+	return getDBFromCtx(ctx)
+}
+
 // getDBFromCtx tries to get the DB from context adding it to the pool if not
 // present, unless DB name is unset in the context - it will then fallback to
 // the current DB has been set via `SetDatabase(...)`
@@ -3,6 +3,7 @@ package postgres
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"os/exec"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/prest/prest/v2/adapters"
@@ -199,6 +201,8 @@ func TestWhereByRequest(t *testing.T) {
 		{"Where by request with ltree right descendent", "/prest-test/public/test5?path='$ltreerdesc.Top.*'", []string{`"path" <@ $`}, []string{`'Top.*'`}, nil},
 		{"Where by request with ltree match lquery", "/prest-test/public/test5?path='$ltreematch.Top.*'", []string{`"path" ~ $`}, []string{`'Top.*'`}, nil},
 		{"Where by request with ltree match ltxtquery", "/prest-test/public/test5?path='$ltreematchtxt.Top*'", []string{`"path" @ $`}, []string{`'Top*'`}, nil},
+		{"Where by request with json path", "/prest-test/public/test_jsonb_bug?name=$eq.goku&data->>description=$eq.testing", []string{`"name" = $`, `"data"->>$`, `::text = $`, " AND "}, []string{"goku", "description", "testing"}, nil},
+		{"Where by request with jsonb containment", "/prest-test/public/test_jsonb_bug?data=$contains.{\"term\":\"name\"}", []string{`"data" @> $`, `::jsonb`}, []string{`{"term":"name"}`}, nil},
 	}
 
 	for _, tc := range testCases {
@@ -264,6 +268,43 @@ func TestInvalidWhereByRequest(t *testing.T) {
 	}
 }
 
+func TestWhereByRequestJSONPath(t *testing.T) {
+	var testCases = []struct {
+		description    string
+		url            string
+		expectedSQL    string
+		expectedValues []interface{}
+		err            error
+	}{
+		{"text value", "/?data->>name=$eq.goku", `"data"->>$1::text = $2`, []interface{}{"name", "goku"}, nil},
+		{"jsonb filter between others", "/?a=1&data->>name:jsonb=goku&id=$gt.2", `"a" = $1 AND "data"->>'name' = $2 AND "id" > $3`, []interface{}{"1", "goku", "2"}, nil},
+		{"nested path with quoted keys", "/?data->'obj'->>'emp'=prestd", `"data"->$1::text->>$2::text = $3`, []interface{}{"obj", "emp", "prestd"}, nil},
+		{"key needing no escaping", "/?data->>it's=$ne.x", `"data"->>$1::text != $2`, []interface{}{"it's", "x"}, nil},
+		{"json value", "/?data->obj=$eq.{\"emp\":\"prestd\"}", `"data"->$1::text = $2`, []interface{}{"obj", `{"emp":"prestd"}`}, nil},
+		{"null check", "/?data->>name=$null.", `"data"->>$1::text IS NULL`, []interface{}{"name"}, nil},
+		{"containment", "/?data=$contains.[1,2]", `"data" @> $1::jsonb`, []interface{}{"[1,2]"}, nil},
+		{"containment in a path", "/?data->tags=$contains.[\"go\"]", `"data"->$1::text @> $2::jsonb`, []interface{}{"tags", `["go"]`}, nil},
+		{"containment then another operator", "/?data=$contains.[1]&data=$ne.[2]", `"data" @> $1::jsonb AND "data" != $2`, []interface{}{"[1]", "[2]"}, nil},
+		{"invalid column", "/?da\"ta->>name=goku", "", nil, ErrInvalidIdentifier},
+		{"empty key", "/?data->>=goku", "", nil, ErrInvalidIdentifier},
+	}
+
+	adapter := &Postgres{}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+			where, values, err := adapter.WhereByRequest(req, 1)
+			require.ErrorIs(t, err, tc.err)
+			if tc.err != nil {
+				return
+			}
+			require.Equal(t, tc.expectedSQL, where)
+			require.Equal(t, tc.expectedValues, values)
+		})
+	}
+}
+
 func TestReturningByRequest(t *testing.T) {
 	var testCases = []struct {
 		description string
@@ -443,6 +484,16 @@ func TestInvalidQuery(t *testing.T) {
 	}
 }
 
+func TestExplainCostCtx(t *testing.T) {
+	ctx := context.WithValue(context.Background(), pctx.DBNameKey, "prest-test")
+	cost, err := config.PrestConf.Adapter.ExplainCostCtx(ctx, `SELECT * FROM "prest-test"."public"."test2" WHERE number = $1`, 1)
+	require.NoError(t, err)
+	require.Greater(t, cost, 0.0)
+
+	_, err = config.PrestConf.Adapter.ExplainCostCtx(ctx, `SELECT * FROM "prest-test"."public"."unknown"`)
+	require.Error(t, err)
+}
+
 func TestPaginateIfPossible(t *testing.T) {
 	var testCase = []struct {
 		description string
@@ -523,6 +574,13 @@ func TestInsert(t *testing.T) {
 	}
 }
 
+func TestInsertSkippedByTrigger(t *testing.T) {
+	// only ON CONFLICT DO NOTHING may leave an insert without a row, the
+	// callers tell it apart
+	sc := config.PrestConf.Adapter.Insert(`INSERT INTO "prest-test"."public"."test_skip_insert"("name") VALUES($1)`, "skipped")
+	require.ErrorIs(t, sc.Err(), sql.ErrNoRows)
+}
+
 func TestInsertCtx(t *testing.T) {
 	var testCases = []struct {
 		description string
@@ -1600,10 +1658,11 @@ func TestCacheDelete(t *testing.T) {
 func BenchmarkPrepare(b *testing.B) {
 	db := connection.MustGet()
 	for index := 0; index < b.N; index++ {
-		_, err := Prepare(db, `SELECT * FROM "TestCase"`)
+		_, release, err := Prepare(db, `SELECT * FROM "TestCase"`)
 		if err != nil {
 			b.Fail()
 		}
+		release()
 	}
 }
 
@@ -1622,6 +1681,99 @@ func TestDisableCache(t *testing.T) {
 	}
 }
 
+func TestStmtCacheLRU(t *testing.T) {
+	config.Load()
+	Load()
+	config.PrestConf.PGCacheSize = 2
+	defer func() { config.PrestConf.PGCacheSize = 500 }()
+	ClearStmt()
+	db := connection.MustGet()
+	for _, SQL := range []string{`SELECT 1`, `SELECT 2`, `SELECT 1`, `SELECT 3`} {
+		_, release, err := Prepare(db, SQL)
+		require.NoError(t, err)
+		release()
+	}
+	require.Equal(t, 2, stmts.Len())
+	require.Contains(t, stmts.PrepareMap, `SELECT 1`)
+	require.NotContains(t, stmts.PrepareMap, `SELECT 2`)
+	require.Contains(t, stmts.PrepareMap, `SELECT 3`)
+
+	stmts.Evict()
+	require.Equal(t, 0, stmts.Len())
+	require.Empty(t, stmts.PrepareMap)
+}
+
+func TestStmtCacheConcurrentEviction(t *testing.T) {
+	config.Load()
+	Load()
+	config.PrestConf.PGCacheSize = 1
+	defer func() { config.PrestConf.PGCacheSize = 500 }()
+	ClearStmt()
+	db := connection.MustGet()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stmt, release, err := Prepare(db, fmt.Sprintf("SELECT %d", i%4))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer release()
+			if i%50 == 0 {
+				GetStmt().Evict()
+			}
+			var n int
+			if err = stmt.QueryRow().Scan(&n); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err, "a statement in use was closed")
+	}
+	require.LessOrEqual(t, stmts.Len(), 1)
+}
+
+func TestIsDDL(t *testing.T) {
+	for SQL, expected := range map[string]bool{
+		`CREATE TABLE t (id int)`:     true,
+		` alter table t add c int`:    true,
+		"\nDROP INDEX i":              true,
+		`TRUNCATE t`:                  true,
+		`SELECT * FROM "created"`:     false,
+		`INSERT INTO t VALUES (1)`:    false,
+		`UPDATE t SET dropped = true`: false,
+	} {
+		require.Equal(t, expected, isDDL(SQL), SQL)
+	}
+}
+
+// BenchmarkQueryCache compares a hot read with and without the statement
+// cache, run with: go test -run NONE -bench QueryCache ./adapters/postgres
+func BenchmarkQueryCache(b *testing.B) {
+	config.Load()
+	Load()
+	defer func(cache bool) { config.PrestConf.PGCache = cache }(config.PrestConf.PGCache)
+	for _, cache := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%v", cache), func(b *testing.B) {
+			config.PrestConf.PGCache = cache
+			ClearStmt()
+			for index := 0; index < b.N; index++ {
+				sc := config.PrestConf.Adapter.Query(`SELECT * FROM "TestCase" WHERE "id" = $1`, 1)
+				if sc.Err() != nil {
+					b.Fatal(sc.Err())
+				}
+			}
+		})
+	}
+}
+
 func TestParseBatchInsertRequest(t *testing.T) {
 	config.Load()
 	Load()
@@ -1673,6 +1825,67 @@ func TestParseBatchInsertRequest(t *testing.T) {
 	}
 }
 
+func TestOnConflictByRequest(t *testing.T) {
+	var testCases = []struct {
+		description string
+		url         string
+		names       string
+		expected    string
+		doNothing   bool
+		err         error
+	}{
+		{"no conflict", "/", `"id", "name"`, "", false, nil},
+		{"do nothing by default", "/?_on_conflict=name", `"id", "name"`, ` ON CONFLICT ("name") DO NOTHING`, true, nil},
+		{"do nothing", "/?_on_conflict=name&_do=nothing", `"id", "name"`, ` ON CONFLICT ("name") DO NOTHING`, true, nil},
+		{"do update", "/?_on_conflict=id&_do=update", `"id", "name", "data"`, ` ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "data" = EXCLUDED."data"`, false, nil},
+		{"do update of a batch", "/?_on_conflict=id,name&_do=update", `"data","id","name"`, ` ON CONFLICT ("id", "name") DO UPDATE SET "data" = EXCLUDED."data"`, false, nil},
+		{"do update without other columns", "/?_on_conflict=name&_do=update", `"name"`, ` ON CONFLICT ("name") DO NOTHING`, true, nil},
+		{"invalid column", "/?_on_conflict=name)--", `"name"`, "", false, ErrInvalidIdentifier},
+		{"column starting with a digit", "/?_on_conflict=1name", `"name"`, "", false, ErrInvalidIdentifier},
+		{"column with a hyphen", "/?_on_conflict=id,first-name", `"name"`, "", false, ErrInvalidIdentifier},
+		{"empty column", "/?_on_conflict=id,", `"name"`, "", false, ErrInvalidIdentifier},
+		{"qualified column", "/?_on_conflict=test.name", `"name"`, "", false, ErrInvalidIdentifier},
+		{"invalid action", "/?_on_conflict=name&_do=delete", `"name"`, "", false, ErrInvalidConflictAction},
+	}
+
+	adapter := &Postgres{}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tc.url, nil)
+			require.NoError(t, err)
+			conflictSyntax, doNothing, err := adapter.OnConflictByRequest(req, tc.names)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.expected, conflictSyntax)
+			require.Equal(t, tc.doNothing, doNothing)
+		})
+	}
+}
+
+func TestParseBatchInsertRequestValidation(t *testing.T) {
+	config.Load()
+	Load()
+	defer func(max int) { config.PrestConf.BatchMaxSize = max }(config.PrestConf.BatchMaxSize)
+	config.PrestConf.BatchMaxSize = 2
+
+	var testCases = []struct {
+		description string
+		body        string
+		err         error
+	}{
+		{"invalid column", `[{"name": "a"}, {"name\"; DROP TABLE test; --": "b"}]`, ErrInvalidIdentifier},
+		{"too many rows", `[{"name": "a"}, {"name": "b"}, {"name": "c"}]`, ErrBatchTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			_, _, _, err = config.PrestConf.Adapter.ParseBatchInsertRequest(req)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
 func TestBatchInsertValues(t *testing.T) {
 	config.Load()
 	Load()
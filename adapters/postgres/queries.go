@@ -8,7 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	gotemplate "text/template"
+	"sync"
 
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/adapters/postgres/internal/connection"
@@ -51,29 +51,35 @@ func (adapter *Postgres) GetScript(verb, folder, scriptName string) (script stri
 	return
 }
 
-// ParseScript use values sent by users and add on script
-func (adapter *Postgres) ParseScript(scriptPath string, templateData map[string]interface{}) (sqlQuery string, values []interface{}, err error) {
-	_, tplName := filepath.Split(scriptPath)
+// scriptStores caches the parsed scripts, one template.Store per directory
+var (
+	scriptStores   = make(map[string]*template.Store)
+	scriptStoresMu sync.Mutex
+)
 
-	funcs := &template.FuncRegistry{TemplateData: templateData}
-	tpl := gotemplate.New(tplName).Funcs(funcs.RegistryAllFuncs())
+// scriptStore returns the store of the scripts in dir, in debug mode it
+// parses the scripts again when they change on disk
+func scriptStore(dir string) *template.Store {
+	scriptStoresMu.Lock()
+	defer scriptStoresMu.Unlock()
+	store, ok := scriptStores[dir]
+	if !ok {
+		store = template.NewStore(dir)
+		store.Debug = config.PrestConf.Debug
+		store.EnvAllowList = config.PrestConf.QueriesEnvAllowList
+		scriptStores[dir] = store
+	}
+	return store
+}
 
-	tpl, err = tpl.ParseFiles(scriptPath)
+// ParseScript use values sent by users and add on script
+func (adapter *Postgres) ParseScript(scriptPath string, templateData map[string]interface{}) (sqlQuery string, values []interface{}, err error) {
+	dir, tplName := filepath.Split(scriptPath)
+	sqlQuery, values, err = scriptStore(filepath.Clean(dir)).Render(tplName, templateData)
 	if err != nil {
 		slog.Error("could not parse file", "scriptPath", scriptPath, "err", err)
 		err = fmt.Errorf("could not parse file: %w", err)
-		return
-	}
-
-	var buff bytes.Buffer
-	err = tpl.Execute(&buff, funcs.TemplateData)
-	if err != nil {
-		err = fmt.Errorf("could not execute template %v", err)
-		return
 	}
-
-	sqlQuery = buff.String()
-	values = funcs.Args
 	return
 }
 
@@ -85,12 +91,13 @@ func WriteSQL(sql string, values []interface{}) (sc adapters.Scanner) {
 		sc = &scanner.PrestScanner{Error: fmt.Errorf("connection get error: %w", err)}
 		return
 	}
-	stmt, err := Prepare(db, sql)
+	stmt, release, err := Prepare(db, sql)
 	if err != nil {
 		slog.Info("could not prepare sql", "sql", sql, "err", err)
 		sc = &scanner.PrestScanner{Error: fmt.Errorf("could not prepare sql: %w", err)}
 		return
 	}
+	defer release()
 
 	valuesAux := make([]interface{}, 0, len(values))
 	for i := 0; i < len(values); i++ {
@@ -104,6 +111,9 @@ func WriteSQL(sql string, values []interface{}) (sc adapters.Scanner) {
 		sc = &scanner.PrestScanner{Error: err}
 		return
 	}
+	if isDDL(sql) {
+		GetStmt().Evict()
+	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -131,12 +141,13 @@ func WriteSQLCtx(ctx context.Context, sql string, values []interface{}) (sc adap
 		sc = &scanner.PrestScanner{Error: fmt.Errorf("connection get error: %w", err)}
 		return
 	}
-	stmt, err := Prepare(db, sql)
+	stmt, release, err := Prepare(db, sql)
 	if err != nil {
 		slog.Info("could not prepare sql", "sql", sql, "err", err)
 		sc = &scanner.PrestScanner{Error: fmt.Errorf("could not prepare sql: %w", err)}
 		return
 	}
+	defer release()
 
 	valuesAux := make([]interface{}, 0, len(values))
 	for i := 0; i < len(values); i++ {
@@ -150,6 +161,9 @@ func WriteSQLCtx(ctx context.Context, sql string, values []interface{}) (sc adap
 		sc = &scanner.PrestScanner{Error: err}
 		return
 	}
+	if isDDL(sql) {
+		GetStmt().Evict()
+	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -111,6 +111,31 @@ ORDER BY
 	// SchemaTables default query
 	SchemaTables = SchemaTablesSelect + SchemaTablesWhere + SchemaTablesOrderBy
 
+	// Columns list the columns of every table and view, flagging the
+	// primary keys
+	Columns = `
+SELECT
+	c.table_schema as "schema",
+	c.table_name as "table",
+	c.column_name as "name",
+	c.data_type as "type",
+	c.is_nullable = 'YES' as "nullable",
+	pk.column_name IS NOT NULL as "primary_key"
+FROM
+	information_schema.columns c
+LEFT JOIN (
+	SELECT kcu.table_schema, kcu.table_name, kcu.column_name
+	FROM information_schema.table_constraints tc
+	INNER JOIN information_schema.key_column_usage kcu
+		ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+	WHERE tc.constraint_type = 'PRIMARY KEY'
+) pk ON pk.table_schema = c.table_schema AND pk.table_name = c.table_name AND pk.column_name = c.column_name
+WHERE
+	c.table_schema NOT IN ('information_schema', 'pg_catalog') AND
+	c.table_schema !~ '^pg_toast'
+ORDER BY
+	c.table_schema, c.table_name, c.ordinal_position`
+
 	// SelectInTable default query
 	SelectInTable = `
 SELECT
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrationName   = regexp.MustCompile(`^[a-z0-9_]+$`)
+	migrationPrefix = regexp.MustCompile(`^(\d+)_`)
+)
+
+// createCmd represents the create command
+var createCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create empty up and down migration files",
+	Long:  `Create NNN_name.up.sql and NNN_name.down.sql in the migrations path, numbered after the last migration`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if path == "" {
+			return ErrPathNotSet
+		}
+		created, err := createMigration(path, args[0])
+		if err != nil {
+			return err
+		}
+		for _, f := range created {
+			fmt.Fprintf(os.Stdout, "%v created\n", f)
+		}
+		return nil
+	},
+}
+
+// createMigration writes the up and down files of the next migration in
+// dir, it never overwrites an existing file
+func createMigration(dir, name string) ([]string, error) {
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ups, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	for _, f := range ups {
+		m := migrationPrefix.FindStringSubmatch(filepath.Base(f))
+		if m == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%03d_%s", next, name))
+	created := make([]string, 0, 2)
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		if err := createEmpty(base + suffix); err != nil {
+			// leave no half created migration behind
+			for _, f := range created {
+				os.Remove(f) // nolint
+			}
+			return nil, err
+		}
+		created = append(created, base+suffix)
+	}
+	return created, nil
+}
+
+func createEmpty(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("migration %v already exists", name)
+		}
+		return err
+	}
+	return f.Close()
+}
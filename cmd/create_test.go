@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	created, err := createMigration(dir, "Create users")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "001_create_users.up.sql"),
+		filepath.Join(dir, "001_create_users.down.sql"),
+	}, created)
+
+	created, err = createMigration(dir, "add_email")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "002_add_email.up.sql"), created[0])
+
+	_, err = createMigration(dir, "../escape")
+	require.Error(t, err)
+}
+
+func TestCreateMigrationDoesNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	down := filepath.Join(dir, "001_users.down.sql")
+	require.NoError(t, os.WriteFile(down, []byte("DROP TABLE users;"), 0o600))
+
+	_, err := createMigration(dir, "users")
+	require.ErrorContains(t, err, "already exists")
+
+	b, err := os.ReadFile(down)
+	require.NoError(t, err)
+	require.Equal(t, "DROP TABLE users;", string(b))
+	require.NoFileExists(t, filepath.Join(dir, "001_users.up.sql"))
+}
@@ -15,6 +15,9 @@ var downCmd = &cobra.Command{
 	Long:    `Roll back all migrations`,
 	PreRunE: checkTable,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if dryRunMode {
+			return dryRun(cmd.Context(), os.Stdout, func(_, _ int) int { return 0 })
+		}
 		n, executed, err := migration.Run(cmd.Context(), path, urlConn, "down")
 		if err != nil {
 			return err
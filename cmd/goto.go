@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gosidekick/migration/v3"
+	"github.com/spf13/cobra"
+)
+
+// gotoCmd represents the goto command
+var gotoCmd = &cobra.Command{
+	Use:     "goto <version>",
+	Short:   "Migrate up or down to the given version",
+	Long:    `Migrate up or down to the given version, 0 rolls back every migration`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: checkTable,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		current, err := currentVersion(cmd.Context(), urlConn)
+		if err != nil {
+			return err
+		}
+		plan, err := planMigration(path, current, target)
+		if err != nil {
+			return err
+		}
+		if dryRunMode {
+			return printPlan(os.Stdout, plan, true)
+		}
+		if len(plan.Files) == 0 {
+			fmt.Fprintf(os.Stdout, "already at version %v\n", target)
+			return nil
+		}
+		// up takes the version to stop at, down the number of steps back
+		op := "up " + strconv.Itoa(target)
+		if target < current {
+			op = "down " + strconv.Itoa(current-target)
+		}
+		n, executed, err := migration.Run(cmd.Context(), path, urlConn, op)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "exec migrations located in %v\n", path)
+		fmt.Fprintf(os.Stdout, "executed %v migrations\n", n)
+		for _, e := range executed {
+			fmt.Fprintf(os.Stdout, "%v SUCCESS\n", e)
+		}
+		return nil
+	},
+}
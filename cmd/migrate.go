@@ -14,8 +14,9 @@ import (
 )
 
 var (
-	urlConn string
-	path    string
+	urlConn    string
+	path       string
+	dryRunMode bool
 )
 
 var (
@@ -38,6 +39,10 @@ func checkTable(cmd *cobra.Command, args []string) error {
 		return ErrURLNotSet
 	}
 	cmd.SilenceUsage = true
+	if dryRunMode {
+		// leave schema_migrations untouched
+		return nil
+	}
 	if config.PrestConf.Adapter == nil {
 		postgres.Load()
 	}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gosidekick/migration/v3"
@@ -19,17 +20,29 @@ var nextCmd = &cobra.Command{
 		if len(args) != 1 {
 			return fmt.Errorf("invalid arguments %v", args)
 		}
-		var (
-			n        int
-			executed []string
-			err      error
-		)
 		a := args[0]
-		if strings.HasPrefix(a, "+") {
-			n, executed, err = migration.Run(cmd.Context(), path, urlConn, "up "+strings.TrimPrefix(a, "+"))
-		} else {
-			n, executed, err = migration.Run(cmd.Context(), path, urlConn, "down "+strings.TrimPrefix(a, "-"))
+		up := strings.HasPrefix(a, "+")
+		steps, err := strconv.Atoi(strings.TrimLeft(a, "+-"))
+		if err != nil {
+			return fmt.Errorf("invalid arguments %v", args)
+		}
+		current, err := currentVersion(cmd.Context(), urlConn)
+		if err != nil {
+			return err
+		}
+		target := current - steps
+		if up {
+			target = current + steps
+		}
+		if dryRunMode {
+			return dryRun(cmd.Context(), os.Stdout, func(_, _ int) int { return target })
+		}
+		// up takes the version to stop at, down the number of steps back
+		op := "down " + strconv.Itoa(steps)
+		if up {
+			op = "up " + strconv.Itoa(target)
 		}
+		n, executed, err := migration.Run(cmd.Context(), path, urlConn, op)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationPlan lists the files run to move the schema From one version To
+// another, in execution order
+type migrationPlan struct {
+	From  int
+	To    int
+	Files []string
+}
+
+// currentVersion reads the latest version recorded in schema_migrations,
+// it is 0 when the table was not created yet
+func currentVersion(ctx context.Context, url string) (int, error) {
+	db, err := sqlx.ConnectContext(ctx, "postgres", url)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var exists bool
+	err = db.GetContext(ctx, &exists, `SELECT to_regclass('schema_migrations') IS NOT NULL`)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var v int
+	err = db.GetContext(ctx, &v, `SELECT coalesce(max("version"),0) FROM schema_migrations`)
+	return v, err
+}
+
+// planMigration resolves the up or down files of dir that move the schema
+// from version current to target
+func planMigration(dir string, current, target int) (migrationPlan, error) {
+	plan := migrationPlan{From: current, To: target}
+	ups, err := migrationFiles(dir)
+	if err != nil {
+		return plan, err
+	}
+	if target < 0 || target > len(ups) {
+		return plan, fmt.Errorf("unknown migration version %v, %v has versions 0 to %v", target, dir, len(ups))
+	}
+	if current > len(ups) {
+		return plan, fmt.Errorf("database is at version %v but %v only has %v migrations", current, dir, len(ups))
+	}
+	if target >= current {
+		plan.Files = ups[current:target]
+		return plan, nil
+	}
+	downs, err := filepath.Glob(filepath.Join(dir, "*.down.sql"))
+	if err != nil {
+		return plan, err
+	}
+	if len(downs) < current {
+		return plan, fmt.Errorf("missing down migrations in %v", dir)
+	}
+	sort.Strings(downs)
+	sort.Sort(sort.Reverse(sort.StringSlice(downs[:current])))
+	plan.Files = downs[:current-target]
+	return plan, nil
+}
+
+// printPlan writes the files of plan followed by their SQL when withSQL is set
+func printPlan(w io.Writer, plan migrationPlan, withSQL bool) error {
+	fmt.Fprintf(w, "plan from version %v to %v\n", plan.From, plan.To)
+	for _, f := range plan.Files {
+		fmt.Fprintf(w, "-- %v\n", f)
+		if !withSQL {
+			continue
+		}
+		b, err := os.ReadFile(f) // nolint
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", bytes.TrimSpace(b))
+	}
+	fmt.Fprintf(w, "resulting version %v\n", plan.To)
+	return nil
+}
+
+// dryRun prints what a migration command would execute without applying
+// it, target maps the current version and the number of migrations to the
+// version the command moves to
+func dryRun(ctx context.Context, w io.Writer, target func(current, total int) int) error {
+	current, err := currentVersion(ctx, urlConn)
+	if err != nil {
+		return err
+	}
+	files, err := migrationFiles(path)
+	if err != nil {
+		return err
+	}
+	plan, err := planMigration(path, current, target(current, len(files)))
+	if err != nil {
+		return err
+	}
+	return printPlan(w, plan, true)
+}
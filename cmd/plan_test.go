@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func migrationsDir(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := 1; i <= n; i++ {
+		for _, suffix := range []string{".up.sql", ".down.sql"} {
+			f := filepath.Join(dir, fmt.Sprintf("%03d_m%s", i, suffix))
+			require.NoError(t, os.WriteFile(f, []byte(fmt.Sprintf("SELECT %d;", i)), 0o600))
+		}
+	}
+	return dir
+}
+
+func TestPlanMigration(t *testing.T) {
+	dir := migrationsDir(t, 3)
+	f := func(name string) string { return filepath.Join(dir, name) }
+
+	for _, tc := range []struct {
+		name            string
+		current, target int
+		files           []string
+		err             bool
+	}{
+		{"up", 1, 3, []string{f("002_m.up.sql"), f("003_m.up.sql")}, false},
+		{"down", 3, 1, []string{f("003_m.down.sql"), f("002_m.down.sql")}, false},
+		{"down to zero", 2, 0, []string{f("002_m.down.sql"), f("001_m.down.sql")}, false},
+		{"same version", 2, 2, []string{}, false},
+		{"unknown target", 0, 4, nil, true},
+		{"negative target", 1, -1, nil, true},
+		{"database ahead of files", 5, 1, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := planMigration(dir, tc.current, tc.target)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.files, plan.Files)
+		})
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	dir := migrationsDir(t, 2)
+	plan := migrationPlan{From: 1, To: 2, Files: []string{filepath.Join(dir, "002_m.up.sql")}}
+
+	var buf bytes.Buffer
+	require.NoError(t, printPlan(&buf, plan, false))
+	require.Equal(t, fmt.Sprintf("plan from version 1 to 2\n-- %s\nresulting version 2\n", plan.Files[0]), buf.String())
+
+	buf.Reset()
+	require.NoError(t, printPlan(&buf, plan, true))
+	require.Equal(t, fmt.Sprintf("plan from version 1 to 2\n-- %s\nSELECT 2;\nresulting version 2\n", plan.Files[0]), buf.String())
+}
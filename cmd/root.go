@@ -1,12 +1,24 @@
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/prest/prest/v2/adapters/postgres"
 	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/controllers"
+	"github.com/prest/prest/v2/middlewares"
 	"github.com/prest/prest/v2/router"
 
 	"log/slog"
@@ -20,11 +32,7 @@ var RootCmd = &cobra.Command{
 	Short: "Serve a RESTful API from any PostgreSQL database",
 	Long:  `prestd (PostgreSQL REST), simplify and accelerate development, ⚡ instant, realtime, high-performance on any Postgres application, existing or new`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if config.PrestConf.Adapter == nil {
-			slog.Warn("adapter is not set. Using the default (postgres)")
-			postgres.Load()
-		}
-		startServer()
+		serveCmd.Run(cmd, args)
 	},
 }
 
@@ -33,16 +41,25 @@ var RootCmd = &cobra.Command{
 func Execute() {
 	upCmd.AddCommand(authUpCmd)
 	downCmd.AddCommand(authDownCmd)
+	migrateCmd.AddCommand(createCmd)
 	migrateCmd.AddCommand(downCmd)
 	migrateCmd.AddCommand(mversionCmd)
 	migrateCmd.AddCommand(nextCmd)
 	migrateCmd.AddCommand(redoCmd)
 	migrateCmd.AddCommand(upCmd)
 	migrateCmd.AddCommand(resetCmd)
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Print the migration status as JSON")
+	migrateCmd.AddCommand(statusCmd)
+	migrateCmd.AddCommand(gotoCmd)
+	serveFlags(RootCmd, config.PrestConf)
+	serveFlags(serveCmd, config.PrestConf)
+	RootCmd.AddCommand(serveCmd)
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the build metadata as JSON")
 	RootCmd.AddCommand(versionCmd)
 	RootCmd.AddCommand(migrateCmd)
 	migrateCmd.PersistentFlags().StringVar(&urlConn, "url", driverURL(), "Database driver url")
 	migrateCmd.PersistentFlags().StringVar(&path, "path", config.PrestConf.MigrationsPath, "Migrations directory")
+	migrateCmd.PersistentFlags().BoolVar(&dryRunMode, "dry-run", false, "Print the migrations and SQL that would run without applying them")
 
 	if err := RootCmd.Execute(); err != nil {
 		slog.Error("executing root command", "err", err)
@@ -52,7 +69,23 @@ func Execute() {
 
 // startServer starts the server
 func startServer() {
-	http.Handle(config.PrestConf.ContextPath, router.Routes())
+	mux := http.NewServeMux()
+	registerProbes(mux, config.PrestConf.HealthPrefix, controllers.DefaultCheckList)
+	if config.PrestConf.MetricsEnabled {
+		middlewares.RegisterDBMetrics(postgres.PoolStats)
+		mux.Handle("GET "+config.PrestConf.MetricsPath, middlewares.MetricsHandler())
+	}
+	if config.PrestConf.OpenAPIEnabled && config.PrestConf.OpenAPISwaggerUI {
+		// served as is, the main routes would render the page as JSON
+		mux.HandleFunc("GET "+strings.TrimSuffix(config.PrestConf.ContextPath, "/")+"/_openapi", controllers.SwaggerUI)
+	}
+	accessLogger, err := middlewares.NewAccessLogger(os.Stdout, config.PrestConf.AccessLogFormat)
+	if err != nil {
+		slog.Error("invalid access log configuration", "err", err)
+		os.Exit(1)
+	}
+	middlewares.AccessLogger = accessLogger
+	mux.Handle(config.PrestConf.ContextPath, router.Routes())
 
 	if !config.PrestConf.AccessConf.Restrict {
 		slog.Warn("You are running prestd in public mode.")
@@ -62,16 +95,180 @@ func startServer() {
 		slog.Warn("You are running prestd in debug mode.")
 	}
 	address := config.PrestConf.HTTPHost + ":" + strconv.Itoa(config.PrestConf.HTTPPort)
+	if config.PrestConf.HTTPUnixSocket != "" {
+		address = config.PrestConf.HTTPUnixSocket
+	}
 	slog.Info("listening and serving", slog.String("addr", address), slog.String("context", config.PrestConf.ContextPath))
 
+	if config.PrestConf.PProfEnabled {
+		go startPProf(config.PrestConf.PProfAddr)
+	}
+
+	srv := newServer(address, middlewares.RequestID(mux), config.PrestConf)
 	if config.PrestConf.HTTPSMode {
-		if err := http.ListenAndServeTLS(address, config.PrestConf.HTTPSCert, config.PrestConf.HTTPSKey, nil); err != nil {
-			slog.Error("HTTPS server failed", "err", err)
+		tlsCfg, err := tlsConfig(config.PrestConf)
+		if err != nil {
+			slog.Error("invalid TLS configuration", "err", err)
 			os.Exit(1)
 		}
+		srv.TLSConfig = tlsCfg
 	}
-	if err := http.ListenAndServe(address, nil); err != nil {
-		slog.Error("HTTP server failed", "err", err)
+	config.SetReadOnly(config.PrestConf.ReadOnly)
+	go reloadOnSignal()
+	go shutdownOnSignal(srv)
+	if err := serve(srv, config.PrestConf); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server failed", "https", config.PrestConf.HTTPSMode, "err", err)
 		os.Exit(1)
 	}
 }
+
+// readyTimeout bounds the database ping done by the readiness probe
+const readyTimeout = 2 * time.Second
+
+// registerProbes serves the liveness (/_health) and readiness (/_ready)
+// probes under prefix, ahead of the main routes and their middlewares
+func registerProbes(mux *http.ServeMux, prefix string, checks controllers.CheckList) {
+	mux.HandleFunc("GET "+prefix+"/_health", controllers.Liveness)
+	mux.HandleFunc("GET "+prefix+"/_ready", controllers.WrappedReadinessCheck(checks, readyTimeout))
+}
+
+// pprofMux returns a mux serving the net/http/pprof handlers under /debug/pprof/
+func pprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// startPProf serves the profiling endpoints on their own listener so they
+// are never reachable through the public address
+func startPProf(addr string) {
+	slog.Warn("pprof endpoints are enabled, do not expose them publicly", slog.String("addr", addr))
+	srv := &http.Server{Addr: addr, Handler: pprofMux(), ReadHeaderTimeout: 10 * time.Second}
+	if err := srv.ListenAndServe(); err != nil {
+		slog.Error("pprof server failed", "err", err)
+	}
+}
+
+// newServer builds the http.Server with the timeouts from cfg, a zero
+// timeout disables it
+func newServer(addr string, h http.Handler, cfg *config.Prest) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: time.Duration(cfg.HTTPReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.HTTPReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.HTTPWriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.HTTPIdleTimeout) * time.Second,
+	}
+}
+
+// shutdownTimeout bounds how long in-flight requests may run after a
+// termination signal
+const shutdownTimeout = 30 * time.Second
+
+// reloadOnSignal reloads the configuration on every SIGHUP
+func reloadOnSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := config.Reload(); err != nil {
+			slog.Error("config reload failed", "err", err)
+			continue
+		}
+		slog.Info("config reloaded")
+	}
+}
+
+// shutdownOnSignal gracefully shuts srv down on SIGINT or SIGTERM
+func shutdownOnSignal(srv *http.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	slog.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("shutdown failed", "err", err)
+	}
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig builds the server TLS settings from cfg, rejecting unknown
+// versions and cipher suites. TLS 1.3 suites are not configurable in Go,
+// so the allow-list only applies to older versions
+func tlsConfig(cfg *config.Prest) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[cfg.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS version %q", cfg.TLSMinVersion)
+	}
+	tlsCfg := &tls.Config{MinVersion: minVersion}
+	if len(cfg.TLSCipherSuites) == 0 {
+		return tlsCfg, nil
+	}
+	suites := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+	for _, name := range cfg.TLSCipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		tlsCfg.CipherSuites = append(tlsCfg.CipherSuites, id)
+	}
+	return tlsCfg, nil
+}
+
+// listener is the part of *http.Server used by serve
+type listener interface {
+	ListenAndServe() error
+	ListenAndServeTLS(certFile, keyFile string) error
+	Serve(l net.Listener) error
+	ServeTLS(l net.Listener, certFile, keyFile string) error
+}
+
+// listenUnix listens on the socket at path, replacing a socket file left
+// behind by a previous run
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("could not remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// serve runs srv over TLS when HTTPSMode is set and over plain HTTP
+// otherwise, never both. It binds HTTPUnixSocket instead of the TCP
+// address when set and removes the socket once srv stops
+func serve(srv listener, cfg *config.Prest) error {
+	if cfg.HTTPUnixSocket != "" {
+		l, err := listenUnix(cfg.HTTPUnixSocket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(cfg.HTTPUnixSocket) // nolint
+		if cfg.HTTPSMode {
+			return srv.ServeTLS(l, cfg.HTTPSCert, cfg.HTTPSKey)
+		}
+		return srv.Serve(l)
+	}
+	if cfg.HTTPSMode {
+		return srv.ListenAndServeTLS(cfg.HTTPSCert, cfg.HTTPSKey)
+	}
+	return srv.ListenAndServe()
+}
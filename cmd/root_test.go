@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/controllers"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeListener struct {
+	plain int
+	tls   int
+	unix  int
+	cert  string
+	key   string
+	err   error
+}
+
+func (f *fakeListener) ListenAndServe() error {
+	f.plain++
+	return f.err
+}
+
+func (f *fakeListener) ListenAndServeTLS(certFile, keyFile string) error {
+	f.tls++
+	f.cert, f.key = certFile, keyFile
+	return f.err
+}
+
+func (f *fakeListener) Serve(l net.Listener) error {
+	f.unix++
+	l.Close()
+	return f.err
+}
+
+func (f *fakeListener) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	f.tls++
+	f.cert, f.key = certFile, keyFile
+	return f.Serve(l)
+}
+
+func TestServe(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		f := &fakeListener{}
+		require.NoError(t, serve(f, &config.Prest{}))
+		require.Equal(t, 1, f.plain)
+		require.Equal(t, 0, f.tls)
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		f := &fakeListener{}
+		cfg := &config.Prest{HTTPSMode: true, HTTPSCert: "cert.crt", HTTPSKey: "cert.key"}
+		require.NoError(t, serve(f, cfg))
+		require.Equal(t, 0, f.plain)
+		require.Equal(t, 1, f.tls)
+		require.Equal(t, "cert.crt", f.cert)
+		require.Equal(t, "cert.key", f.key)
+	})
+
+	t.Run("tls error does not fall through", func(t *testing.T) {
+		f := &fakeListener{err: errors.New("bad cert")}
+		err := serve(f, &config.Prest{HTTPSMode: true})
+		require.EqualError(t, err, "bad cert")
+		require.Equal(t, 0, f.plain)
+		require.Equal(t, 1, f.tls)
+	})
+
+	t.Run("unix socket replaces stale socket", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "prest.sock")
+		stale, err := net.Listen("unix", sock)
+		require.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		f := &fakeListener{}
+		require.NoError(t, serve(f, &config.Prest{HTTPUnixSocket: sock}))
+		require.Equal(t, 1, f.unix)
+		require.Equal(t, 0, f.plain)
+		require.NoFileExists(t, sock)
+	})
+
+	t.Run("unix socket path is a regular file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "prest.sock")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		f := &fakeListener{}
+		require.Error(t, serve(f, &config.Prest{HTTPUnixSocket: file}))
+		require.Equal(t, 0, f.unix)
+		require.FileExists(t, file)
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	cfg := &config.Prest{
+		HTTPReadHeaderTimeout: 10,
+		HTTPReadTimeout:       30,
+		HTTPWriteTimeout:      90,
+	}
+	h := http.NewServeMux()
+	srv := newServer("127.0.0.1:3000", h, cfg)
+	require.Equal(t, "127.0.0.1:3000", srv.Addr)
+	require.Equal(t, h, srv.Handler)
+	require.Equal(t, 10*time.Second, srv.ReadHeaderTimeout)
+	require.Equal(t, 30*time.Second, srv.ReadTimeout)
+	require.Equal(t, 90*time.Second, srv.WriteTimeout)
+	require.Zero(t, srv.IdleTimeout)
+}
+
+func TestTLSConfig(t *testing.T) {
+	tlsCfg, err := tlsConfig(&config.Prest{TLSMinVersion: "1.2"})
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+	require.Nil(t, tlsCfg.CipherSuites)
+
+	tlsCfg, err = tlsConfig(&config.Prest{
+		TLSMinVersion:   "1.3",
+		TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), tlsCfg.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, tlsCfg.CipherSuites)
+
+	_, err = tlsConfig(&config.Prest{TLSMinVersion: "1.4"})
+	require.ErrorContains(t, err, "unknown TLS version")
+
+	_, err = tlsConfig(&config.Prest{TLSMinVersion: "1.2", TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	require.ErrorContains(t, err, "cipher suite")
+}
+
+func TestRegisterProbes(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		prefix string
+		check  func(context.Context) error
+		path   string
+		status int
+	}{
+		{"liveness", "", failingCheck, "/_health", http.StatusOK},
+		{"ready", "", passingCheck, "/_ready", http.StatusOK},
+		{"not ready", "", failingCheck, "/_ready", http.StatusServiceUnavailable},
+		{"prefixed liveness", "/ops", passingCheck, "/ops/_health", http.StatusOK},
+		{"prefixed ready", "/ops", passingCheck, "/ops/_ready", http.StatusOK},
+		{"prefix moves probes", "/ops", passingCheck, "/_health", http.StatusNotFound},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			mux := http.NewServeMux()
+			registerProbes(mux, tc.prefix, controllers.CheckList{tc.check})
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
+
+func passingCheck(context.Context) error { return nil }
+func failingCheck(context.Context) error { return errors.New("db down") }
+
+func TestPProfMux(t *testing.T) {
+	mux := pprofMux()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/databases", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
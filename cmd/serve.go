@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/prest/prest/v2/adapters/postgres"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/controllers"
+
+	"github.com/spf13/cobra"
+)
+
+// serveCmd starts the HTTP server, it is also what a bare prestd runs
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the pREST HTTP server",
+	Long:  `Serve the RESTful API, flags override the values read from the config file and environment`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if config.PrestConf.Adapter == nil {
+			slog.Warn("adapter is not set. Using the default (postgres)")
+			postgres.Load()
+		}
+		pinReadOnlyFlag(cmd)
+		if !noDBCheck {
+			if err := checkStartup(cmd.Context(), controllers.DefaultCheckList, dbCheckAttempts, dbCheckWait); err != nil {
+				slog.Error("database is not reachable, use --no-db-check to start anyway", "err", err)
+				os.Exit(1)
+			}
+		}
+		startServer()
+	},
+}
+
+// noDBCheck skips the database check done before serving
+var noDBCheck bool
+
+const (
+	dbCheckAttempts = 3
+	dbCheckWait     = time.Second
+)
+
+// checkStartup runs checks until they all pass, giving up after attempts
+// tries. Each try is bounded by readyTimeout
+func checkStartup(ctx context.Context, checks controllers.CheckList, attempts int, wait time.Duration) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			slog.Warn("startup check failed, retrying", "attempt", i, "err", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		if err = runChecks(ctx, checks); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func runChecks(ctx context.Context, checks controllers.CheckList) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	for _, check := range checks {
+		if err := check(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinReadOnlyFlag keeps read-only mode on across config reloads when it was
+// turned on with --read-only, which the config file can't override
+func pinReadOnlyFlag(cmd *cobra.Command) {
+	if on, _ := cmd.Flags().GetBool("read-only"); on && cmd.Flags().Changed("read-only") {
+		config.PinReadOnly(true)
+	}
+}
+
+// serveFlags binds the serve flags to the loaded configuration
+func serveFlags(cmd *cobra.Command, cfg *config.Prest) {
+	cmd.Flags().IntVar(&cfg.HTTPPort, "port", cfg.HTTPPort, "HTTP port to listen on")
+	cmd.Flags().StringVar(&cfg.HTTPHost, "host", cfg.HTTPHost, "HTTP address to listen on")
+	cmd.Flags().StringVar(&cfg.ContextPath, "context-path", cfg.ContextPath, "Path prefix of the API routes")
+	cmd.Flags().BoolVar(&noDBCheck, "no-db-check", false, "Start without checking that the database is reachable")
+	cmd.Flags().BoolVar(&cfg.PProfEnabled, "pprof", cfg.PProfEnabled, "Serve pprof profiles on the pprof address, only expose it on a private port")
+	cmd.Flags().BoolVar(&cfg.ReadOnly, "read-only", cfg.ReadOnly, "Reject writes with 503 until restarted, without it readonly in the config file is applied on SIGHUP")
+}
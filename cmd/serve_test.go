@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/controllers"
+	"github.com/prest/prest/v2/middlewares"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestServeFlags(t *testing.T) {
+	cfg := &config.Prest{HTTPHost: "0.0.0.0", HTTPPort: 3000, ContextPath: "/"}
+	cmd := &cobra.Command{Use: "serve"}
+	serveFlags(cmd, cfg)
+
+	require.NoError(t, cmd.ParseFlags([]string{"--port", "8080", "--context-path", "/api", "--read-only"}))
+	require.Equal(t, 8080, cfg.HTTPPort)
+	require.Equal(t, "0.0.0.0", cfg.HTTPHost)
+	require.Equal(t, "/api", cfg.ContextPath)
+	require.False(t, cfg.PProfEnabled)
+	require.True(t, cfg.ReadOnly)
+}
+
+func TestReadOnlyFlagSurvivesReload(t *testing.T) {
+	defer config.SetReadOnly(false)
+	defer config.PinReadOnly(false)
+	file := filepath.Join(t.TempDir(), "readonly-flag.toml")
+	require.NoError(t, os.WriteFile(file, []byte("readonly = false\n"), 0600))
+	t.Setenv("PREST_CONF", file)
+	config.Load()
+
+	cmd := &cobra.Command{Use: "serve"}
+	serveFlags(cmd, config.PrestConf)
+	require.NoError(t, cmd.ParseFlags([]string{"--read-only"}))
+	pinReadOnlyFlag(cmd)
+	config.SetReadOnly(config.PrestConf.ReadOnly)
+
+	require.NoError(t, config.Reload())
+	n := negroni.New(middlewares.ReadOnly())
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	n.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/prest-test/public/test", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code, "writes are still rejected after a reload")
+}
+
+func TestCheckStartup(t *testing.T) {
+	calls := 0
+	flaky := func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	require.NoError(t, checkStartup(context.Background(), controllers.CheckList{flaky}, 3, time.Millisecond))
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	err := checkStartup(context.Background(), controllers.CheckList{flaky}, 2, time.Millisecond)
+	require.EqualError(t, err, "connection refused")
+	require.Equal(t, 2, calls)
+}
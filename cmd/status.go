@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/cobra"
+)
+
+// statusJSON prints the migration status as JSON
+var statusJSON bool
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:     "status",
+	Short:   "List applied and pending migrations",
+	Long:    `List every migration in the migrations path, marked applied or pending against schema_migrations`,
+	PreRunE: checkTable,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := migrationFiles(path)
+		if err != nil {
+			return err
+		}
+		applied, err := appliedVersions(cmd.Context(), urlConn)
+		if err != nil {
+			return err
+		}
+		return printStatus(os.Stdout, buildStatus(files, applied), statusJSON)
+	},
+}
+
+type migrationStatus struct {
+	Version int    `json:"version"`
+	File    string `json:"file"`
+	Applied bool   `json:"applied"`
+}
+
+type statusReport struct {
+	CurrentVersion int               `json:"current_version"`
+	Migrations     []migrationStatus `json:"migrations"`
+}
+
+// migrationFiles lists the up files of dir in the order they are applied,
+// the version of a migration is its position in that list starting at 1
+func migrationFiles(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%v is not a directory", dir)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// appliedVersions reads the versions recorded in schema_migrations
+func appliedVersions(ctx context.Context, url string) ([]int, error) {
+	db, err := sqlx.ConnectContext(ctx, "postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var versions []int
+	err = db.SelectContext(ctx, &versions, `SELECT "version" FROM schema_migrations ORDER BY "version"`)
+	return versions, err
+}
+
+func buildStatus(files []string, applied []int) statusReport {
+	done := make(map[int]bool, len(applied))
+	report := statusReport{Migrations: make([]migrationStatus, 0, len(files))}
+	for _, v := range applied {
+		done[v] = true
+		if v > report.CurrentVersion {
+			report.CurrentVersion = v
+		}
+	}
+	for i, f := range files {
+		report.Migrations = append(report.Migrations, migrationStatus{
+			Version: i + 1,
+			File:    filepath.Base(f),
+			Applied: done[i+1],
+		})
+	}
+	return report
+}
+
+func printStatus(w io.Writer, report statusReport, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(report)
+	}
+	fmt.Fprintf(w, "current version %v\n", report.CurrentVersion)
+	for _, m := range report.Migrations {
+		state := "pending"
+		if m.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%4d %-8s %v\n", m.Version, state, m.File)
+	}
+	return nil
+}
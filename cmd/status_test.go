@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"002_b.up.sql", "001_a.up.sql", "001_a.down.sql"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), nil, 0o600))
+	}
+	files, err := migrationFiles(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "001_a.up.sql"), filepath.Join(dir, "002_b.up.sql")}, files)
+
+	_, err = migrationFiles(filepath.Join(dir, "001_a.up.sql"))
+	require.Error(t, err)
+}
+
+func TestBuildStatus(t *testing.T) {
+	report := buildStatus([]string{"m/001_a.up.sql", "m/002_b.up.sql", "m/003_c.up.sql"}, []int{1, 2})
+	require.Equal(t, 2, report.CurrentVersion)
+	require.Equal(t, []migrationStatus{
+		{Version: 1, File: "001_a.up.sql", Applied: true},
+		{Version: 2, File: "002_b.up.sql", Applied: true},
+		{Version: 3, File: "003_c.up.sql", Applied: false},
+	}, report.Migrations)
+
+	var buf bytes.Buffer
+	require.NoError(t, printStatus(&buf, report, false))
+	require.Equal(t, "current version 2\n   1 applied  001_a.up.sql\n   2 applied  002_b.up.sql\n   3 pending  003_c.up.sql\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, printStatus(&buf, buildStatus(nil, nil), true))
+	require.JSONEq(t, `{"current_version":0,"migrations":[]}`, buf.String())
+}
@@ -15,6 +15,9 @@ var upCmd = &cobra.Command{
 	Long:    `Apply all available migrations`,
 	PreRunE: checkTable,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if dryRunMode {
+			return dryRun(cmd.Context(), os.Stdout, func(_, total int) int { return total })
+		}
 		n, executed, err := migration.Run(cmd.Context(), path, urlConn, "up")
 		if err != nil {
 			return err
@@ -1,19 +1,34 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/prest/prest/v2/helpers"
 
 	"github.com/spf13/cobra"
 )
 
+// versionJSON prints the build metadata as JSON
+var versionJSON bool
+
 // versionCmd show version pREST
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of pREST",
 	Long:  `All software has versions. This is pREST's`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Simplify and accelerate development, ⚡ instant, realtime, high-performance on any Postgres application, existing or new", helpers.PrestReleaseVersion())
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printVersion(cmd.OutOrStdout(), helpers.Build(), versionJSON)
 	},
 }
+
+func printVersion(w io.Writer, info helpers.BuildInfo, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(info)
+	}
+	_, err := fmt.Fprintf(w, "Simplify and accelerate development, ⚡ instant, realtime, high-performance on any Postgres application, existing or new\n"+
+		"version:    %s\ngit commit: %s\nbuild date: %s\ngo version: %s\n",
+		info.Version, info.GitCommit, info.BuildDate, info.GoVersion)
+	return err
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prest/prest/v2/helpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintVersion(t *testing.T) {
+	info := helpers.BuildInfo{Version: "1.4.0", GitCommit: "abc123", BuildDate: "unknown", GoVersion: "go1.25.0"}
+
+	var buf bytes.Buffer
+	require.NoError(t, printVersion(&buf, info, false))
+	require.Contains(t, buf.String(), "version:    1.4.0\n")
+	require.Contains(t, buf.String(), "git commit: abc123\n")
+
+	buf.Reset()
+	require.NoError(t, printVersion(&buf, info, true))
+	require.JSONEq(t, `{"version":"1.4.0","git_commit":"abc123","build_date":"unknown","go_version":"go1.25.0"}`, buf.String())
+}
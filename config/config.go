@@ -57,6 +57,15 @@ type ExposeConf struct {
 	TableListing    bool
 }
 
+// APIKeyConf is an API key accepted in the X-API-Key header, stored as the
+// hex SHA-256 of the key. Several keys may map to the same role to rotate them
+type APIKeyConf struct {
+	Name string `mapstructure:"name"`
+	Hash string `mapstructure:"hash"`
+	// Role is the user the key authenticates as, matched against access.users
+	Role string `mapstructure:"role"`
+}
+
 type PluginMiddleware struct {
 	File string
 	Func string
@@ -64,57 +73,109 @@ type PluginMiddleware struct {
 
 // Prest basic config
 type Prest struct {
-	AuthEnabled          bool
-	AuthSchema           string
-	AuthTable            string
-	AuthUsername         string
-	AuthPassword         string
-	AuthEncrypt          string
-	AuthMetadata         []string
-	AuthType             string
-	HTTPHost             string // HTTPHost Declare which http address the PREST used
-	HTTPPort             int    // HTTPPort Declare which http port the PREST used
-	HTTPTimeout          int
-	PGHost               string
-	PGPort               int
-	PGUser               string
-	PGPass               string
-	PGDatabase           string
-	PGURL                string
-	PGSSLMode            string
-	PGSSLCert            string
-	PGSSLKey             string
-	PGSSLRootCert        string
-	ContextPath          string
-	PGMaxIdleConn        int
-	PGMaxOpenConn        int
-	PGConnTimeout        int
-	PGCache              bool
-	JWTKey               string
-	JWTAlgo              string
-	JWTWellKnownURL      string
-	JWTJWKS              string
-	JWTWhiteList         []string
-	JSONAggType          string
-	MigrationsPath       string
-	QueriesPath          string
-	AccessConf           AccessConf
-	ExposeConf           ExposeConf
-	CORSAllowOrigin      []string
-	CORSAllowHeaders     []string
-	CORSAllowMethods     []string
-	CORSAllowCredentials bool
-	Debug                bool
-	Adapter              adapters.Adapter
-	EnableDefaultJWT     bool
-	SingleDB             bool
-	HTTPSMode            bool
-	HTTPSCert            string
-	HTTPSKey             string
-	Cache                cache.Config
-	PluginPath           string
-	PluginMiddlewareList []PluginMiddleware
-	Logger               *slog.Logger
+	AuthEnabled           bool
+	AuthSchema            string
+	AuthTable             string
+	AuthUsername          string
+	AuthPassword          string
+	AuthEncrypt           string
+	AuthMetadata          []string
+	AuthType              string
+	HTTPHost              string // HTTPHost Declare which http address the PREST used
+	HTTPPort              int    // HTTPPort Declare which http port the PREST used
+	HTTPUnixSocket        string // HTTPUnixSocket serves on this socket path instead of HTTPHost:HTTPPort
+	HTTPTimeout           int
+	RequestTimeout        int // RequestTimeout in seconds cancels slow requests, 0 disables it
+	HTTPReadHeaderTimeout int // HTTPReadHeaderTimeout in seconds, see http.Server
+	HTTPReadTimeout       int // HTTPReadTimeout in seconds, see http.Server
+	HTTPWriteTimeout      int // HTTPWriteTimeout in seconds, see http.Server
+	HTTPIdleTimeout       int // HTTPIdleTimeout in seconds, see http.Server
+	PGHost                string
+	PGPort                int
+	PGUser                string
+	PGPass                string
+	PGDatabase            string
+	PGURL                 string
+	PGReplicaURLs         []string // PGReplicaURLs serve the read queries of PGDatabase
+	PGSSLMode             string
+	PGSSLCert             string
+	PGSSLKey              string
+	PGSSLRootCert         string
+	ContextPath           string
+	HealthPrefix          string // HealthPrefix is prepended to the /_health and /_ready probe paths
+	PProfEnabled          bool   // PProfEnabled serves net/http/pprof on PProfAddr
+	PProfAddr             string
+	MetricsEnabled        bool // MetricsEnabled serves prometheus metrics on MetricsPath
+	MetricsPath           string
+	AccessLogFormat       string // AccessLogFormat is "text" or "json"
+	CSVDelimiter          string // CSVDelimiter separates the fields of CSV responses
+	ETagEnabled           bool   // ETagEnabled hashes GET responses to answer conditional requests
+	ETagMaxSize           int    // ETagMaxSize in bytes, larger responses are sent without an ETag
+	GzipEnabled           bool
+	GzipMinLength         int // GzipMinLength in bytes, smaller responses are sent uncompressed
+	RateLimitEnabled      bool
+	RateLimitRPS          float64 // RateLimitRPS is the sustained requests per second of each client
+	RateLimitBurst        int
+	RateLimitHeader       string // RateLimitHeader keys clients by this header instead of their IP
+	PGMaxIdleConn         int
+	PGMaxOpenConn         int
+	PGConnTimeout         int
+	PGCache               bool
+	PGCacheSize           int // PGCacheSize bounds the prepared statements kept in the cache
+	BatchMaxSize          int // BatchMaxSize caps the rows of a batch insert, 0 disables the limit
+	BatchMaxOperations    int // BatchMaxOperations caps the operations of a /batch transaction
+	RealtimeEnabled       bool
+	RealtimeChannels      []string // RealtimeChannels allow-lists the LISTEN channels, empty allows any
+	RealtimeBuffer        int      // RealtimeBuffer is the notifications queued per client before dropping
+	OpenAPIEnabled        bool
+	ReadOnly              bool    // ReadOnly rejects writes, see IsReadOnly for the live value
+	OpenAPISwaggerUI      bool    // OpenAPISwaggerUI serves Swagger UI at /_openapi
+	GuardMaxRows          int     // GuardMaxRows is the LIMIT of selects without _page or _limit, 0 disables it
+	GuardLimitCap         int     // GuardLimitCap bounds _limit and _page_size, 0 accepts any
+	GuardMaxCost          float64 // GuardMaxCost rejects selects the planner estimates above it, 0 disables it
+	PaginationMode        string  // PaginationMode is the pagination of selects without _paginate, offset or keyset
+	PaginationMaxPageSize int     // PaginationMaxPageSize clamps _page_size and template page sizes, 0 accepts any
+	SoftDeleteEnabled     bool
+	SoftDeleteColumn      string   // SoftDeleteColumn holds the deletion time of soft-deleted rows
+	SoftDeleteTables      []string // SoftDeleteTables lists the schema.table names soft deletes apply to
+	AuditEnabled          bool
+	AuditTable            string // AuditTable is the schema.table of the audit trail, empty logs the entries instead
+	AuditBody             bool   // AuditBody records the request bodies too
+	AuditBodyMaxSize      int64  // AuditBodyMaxSize is the largest request body recorded in bytes, larger requests are rejected
+	AuditQueueSize        int    // AuditQueueSize is the entries waiting to be recorded before dropping new ones
+	JWTKey                string
+	JWTAlgo               string
+	JWTWellKnownURL       string
+	JWTIssuer             string   // JWTIssuer is the iss claim required of tokens, empty accepts any
+	JWTAudience           []string // JWTAudience lists the aud claims accepted, empty accepts any
+	JWTJWKS               string
+	JWTWhiteList          []string
+	APIKeyEnabled         bool
+	APIKeys               []APIKeyConf
+	JSONAggType           string
+	MigrationsPath        string
+	QueriesPath           string
+	QueriesEnvAllowList   []string // QueriesEnvAllowList are the environment variables the env template func may read
+	AccessConf            AccessConf
+	ExposeConf            ExposeConf
+	CORSEnabled           bool
+	CORSAllowOrigin       []string
+	CORSAllowHeaders      []string
+	CORSAllowMethods      []string
+	CORSAllowCredentials  bool
+	Debug                 bool
+	Adapter               adapters.Adapter
+	EnableDefaultJWT      bool
+	SingleDB              bool
+	HTTPSMode             bool
+	HTTPSCert             string
+	HTTPSKey              string
+	TLSMinVersion         string   // TLSMinVersion is "1.0" to "1.3"
+	TLSCipherSuites       []string // TLSCipherSuites restricts the TLS 1.0-1.2 suites, empty keeps Go's defaults
+	Cache                 cache.Config
+	PluginPath            string
+	PluginMiddlewareList  []PluginMiddleware
+	Logger                *slog.Logger
 }
 
 const defaultCacheDir = "./"
@@ -187,6 +248,14 @@ func viperCfg() {
 	viper.SetDefault("http.host", "0.0.0.0")
 	viper.SetDefault("http.port", 3000)
 	viper.SetDefault("http.timeout", 60)
+	viper.SetDefault("http.requesttimeout", 0)
+	viper.SetDefault("http.unixsocket", "")
+	// server timeouts guard against slow clients (slowloris) and leaked
+	// connections, the write timeout must outlast http.timeout
+	viper.SetDefault("http.readheadertimeout", 10)
+	viper.SetDefault("http.readtimeout", 30)
+	viper.SetDefault("http.writetimeout", 90)
+	viper.SetDefault("http.idletimeout", 120)
 
 	viper.SetDefault("pg.host", "127.0.0.1")
 	viper.SetDefault("pg.port", 5432)
@@ -198,6 +267,25 @@ func viperCfg() {
 	viper.SetDefault("pg.conntimeout", 10)
 	viper.SetDefault("pg.single", true)
 	viper.SetDefault("pg.cache", true)
+	viper.SetDefault("pg.cachesize", 500)
+	viper.SetDefault("batch.maxsize", 1000)
+	viper.SetDefault("batch.maxoperations", 100)
+	viper.SetDefault("realtime.enabled", false)
+	viper.SetDefault("realtime.buffer", 64)
+	viper.SetDefault("openapi.enabled", false)
+	viper.SetDefault("readonly", false)
+	viper.SetDefault("guard.maxrows", 0)
+	viper.SetDefault("guard.limitcap", 0)
+	viper.SetDefault("guard.maxcost", 0)
+	viper.SetDefault("pagination.mode", "offset")
+	viper.SetDefault("pagination.maxpagesize", 0)
+	viper.SetDefault("softdelete.enabled", false)
+	viper.SetDefault("softdelete.column", "deleted_at")
+	viper.SetDefault("audit.enabled", false)
+	viper.SetDefault("audit.queuesize", 1000)
+	viper.SetDefault("audit.bodymaxsize", 1<<20)
+	viper.SetDefault("openapi.swaggerui", false)
+	viper.SetDefault("pg.replicas", []string{})
 	// todo: replace this with prefer, will need to replace lib/pq
 	// https://github.com/jackc/pgx/blob/47d631e34be7128997a0aa89b75885cc4ad4c82e/pgconn/config.go#L218
 	viper.SetDefault("pg.ssl.mode", "disable")
@@ -207,9 +295,12 @@ func viperCfg() {
 	viper.SetDefault("jwt.wellknownurl", "")
 	viper.SetDefault("jwt.jwks", "")
 	viper.SetDefault("jwt.whitelist", []string{`^\/auth$`})
+	viper.SetDefault("apikey.enabled", false)
 
 	viper.SetDefault("json.agg.type", "jsonb_agg")
 
+	// on by default for backwards compatibility
+	viper.SetDefault("cors.enabled", true)
 	viper.SetDefault("cors.allowheaders", []string{"Content-Type"})
 	viper.SetDefault("cors.allowmethods", []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"})
 	viper.SetDefault("cors.alloworigin", []string{"*"})
@@ -218,6 +309,8 @@ func viperCfg() {
 	viper.SetDefault("https.mode", false)
 	viper.SetDefault("https.cert", "/etc/certs/cert.crt")
 	viper.SetDefault("https.key", "/etc/certs/cert.key")
+	viper.SetDefault("https.minversion", "1.2")
+	viper.SetDefault("https.ciphersuites", []string{})
 
 	viper.SetDefault("cache.enabled", false)
 	viper.SetDefault("cache.time", 10)
@@ -227,6 +320,22 @@ func viperCfg() {
 	viper.SetDefault("version", 1)
 	viper.SetDefault("debug", false)
 	viper.SetDefault("context", "/")
+	viper.SetDefault("health.prefix", "")
+	// profiling exposes internals, keep it on a private address
+	viper.SetDefault("pprof.enabled", false)
+	viper.SetDefault("pprof.addr", "127.0.0.1:6060")
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.path", "/metrics")
+	viper.SetDefault("accesslog.format", "text")
+	viper.SetDefault("csv.delimiter", ",")
+	viper.SetDefault("etag.enabled", false)
+	viper.SetDefault("etag.maxsize", 1<<20)
+	viper.SetDefault("gzip.enabled", false)
+	viper.SetDefault("gzip.minlength", 1024)
+	viper.SetDefault("ratelimit.enabled", false)
+	viper.SetDefault("ratelimit.rps", 10)
+	viper.SetDefault("ratelimit.burst", 20)
+	viper.SetDefault("ratelimit.header", "")
 	viper.SetDefault("pluginpath", "./lib")
 	viper.SetDefault("pluginmiddlewarelist", []PluginMiddleware{})
 	viper.SetDefault("expose.enabled", false)
@@ -270,6 +379,8 @@ func Parse(cfg *Prest) {
 	cfg.JWTAlgo = viper.GetString("jwt.algo")
 	cfg.JWTWellKnownURL = viper.GetString("jwt.wellknownurl")
 	cfg.JWTJWKS = viper.GetString("jwt.jwks")
+	cfg.JWTIssuer = viper.GetString("jwt.issuer")
+	cfg.JWTAudience = viper.GetStringSlice("jwt.audience")
 	cfg.JWTWhiteList = viper.GetStringSlice("jwt.whitelist")
 	fetchJWKS(cfg)
 
@@ -280,7 +391,9 @@ func Parse(cfg *Prest) {
 	cfg.AccessConf.Restrict = viper.GetBool("access.restrict")
 	cfg.AccessConf.IgnoreTable = viper.GetStringSlice("access.ignore_table")
 	cfg.QueriesPath = viper.GetString("queries.location")
+	cfg.QueriesEnvAllowList = viper.GetStringSlice("queries.envallowlist")
 
+	cfg.CORSEnabled = viper.GetBool("cors.enabled")
 	cfg.CORSAllowOrigin = viper.GetStringSlice("cors.alloworigin")
 	cfg.CORSAllowHeaders = viper.GetStringSlice("cors.allowheaders")
 	cfg.CORSAllowMethods = viper.GetStringSlice("cors.allowmethods")
@@ -289,6 +402,43 @@ func Parse(cfg *Prest) {
 	cfg.Debug = viper.GetBool("debug")
 	cfg.EnableDefaultJWT = viper.GetBool("jwt.default")
 	cfg.ContextPath = viper.GetString("context")
+	cfg.HealthPrefix = strings.TrimSuffix(viper.GetString("health.prefix"), "/")
+	cfg.PProfEnabled = viper.GetBool("pprof.enabled")
+	cfg.PProfAddr = viper.GetString("pprof.addr")
+	cfg.MetricsEnabled = viper.GetBool("metrics.enabled")
+	cfg.MetricsPath = viper.GetString("metrics.path")
+	cfg.AccessLogFormat = viper.GetString("accesslog.format")
+	cfg.CSVDelimiter = viper.GetString("csv.delimiter")
+	cfg.ETagEnabled = viper.GetBool("etag.enabled")
+	cfg.ETagMaxSize = viper.GetInt("etag.maxsize")
+	cfg.GzipEnabled = viper.GetBool("gzip.enabled")
+	cfg.GzipMinLength = viper.GetInt("gzip.minlength")
+	cfg.RateLimitEnabled = viper.GetBool("ratelimit.enabled")
+	cfg.RateLimitRPS = viper.GetFloat64("ratelimit.rps")
+	cfg.RateLimitBurst = viper.GetInt("ratelimit.burst")
+	cfg.RateLimitHeader = viper.GetString("ratelimit.header")
+	cfg.RealtimeEnabled = viper.GetBool("realtime.enabled")
+	cfg.RealtimeChannels = viper.GetStringSlice("realtime.channels")
+	cfg.RealtimeBuffer = viper.GetInt("realtime.buffer")
+	cfg.OpenAPIEnabled = viper.GetBool("openapi.enabled")
+	cfg.OpenAPISwaggerUI = viper.GetBool("openapi.swaggerui")
+	cfg.ReadOnly = viper.GetBool("readonly")
+	cfg.GuardMaxRows = viper.GetInt("guard.maxrows")
+	cfg.GuardLimitCap = viper.GetInt("guard.limitcap")
+	cfg.GuardMaxCost = viper.GetFloat64("guard.maxcost")
+	cfg.PaginationMode = viper.GetString("pagination.mode")
+	cfg.PaginationMaxPageSize = viper.GetInt("pagination.maxpagesize")
+	cfg.SoftDeleteEnabled = viper.GetBool("softdelete.enabled")
+	cfg.SoftDeleteColumn = viper.GetString("softdelete.column")
+	cfg.SoftDeleteTables = viper.GetStringSlice("softdelete.tables")
+	if cfg.SoftDeleteEnabled && len(cfg.SoftDeleteTables) == 0 {
+		slog.Warn("soft delete is enabled without softdelete.tables, no table is soft deleted")
+	}
+	cfg.AuditEnabled = viper.GetBool("audit.enabled")
+	cfg.AuditTable = viper.GetString("audit.table")
+	cfg.AuditBody = viper.GetBool("audit.body")
+	cfg.AuditBodyMaxSize = viper.GetInt64("audit.bodymaxsize")
+	cfg.AuditQueueSize = viper.GetInt("audit.queuesize")
 
 	cfg.PluginPath = viper.GetString("pluginpath")
 
@@ -321,6 +471,14 @@ func Parse(cfg *Prest) {
 		slog.Error("could not unmarshal access plugin middleware list", "err", err)
 	}
 	cfg.PluginMiddlewareList = pluginMiddlewareConfig
+
+	cfg.APIKeyEnabled = viper.GetBool("apikey.enabled")
+	var apikeys []APIKeyConf
+	err = viper.UnmarshalKey("apikey.keys", &apikeys)
+	if err != nil {
+		slog.Error("could not unmarshal api keys", "err", err)
+	}
+	cfg.APIKeys = apikeys
 }
 
 // parseDatabaseURL tries to get from URL the DB configs
@@ -409,15 +567,18 @@ func fetchJWKS(cfg *Prest) {
 	cfg.JWTJWKS = string(jwkSetJSON)
 }
 
+// portFromEnv overrides HTTPPort with the PORT env var when it holds a
+// valid port, invalid values are reported and ignored
 func portFromEnv(cfg *Prest) {
-	if os.Getenv("PORT") == "" {
+	env := os.Getenv("PORT")
+	if env == "" {
 		slog.Debug("could not find PORT in env")
 		return
 	}
 	// cloud factor support: https://help.heroku.com/PPBPA231/how-do-i-use-the-port-environment-variable-in-container-based-apps
-	HTTPPort, err := strconv.Atoi(os.Getenv("PORT"))
-	if err != nil {
-		slog.Debug("could not find PORT in env")
+	HTTPPort, err := strconv.Atoi(env)
+	if err != nil || HTTPPort < 1 || HTTPPort > 65535 {
+		slog.Warn("ignoring invalid PORT env", "port", env, "http_port", cfg.HTTPPort)
 		return
 	}
 	cfg.HTTPPort = HTTPPort
@@ -460,6 +621,10 @@ func parseDBConfig(cfg *Prest) {
 	cfg.PGMaxOpenConn = viper.GetInt("pg.maxopenconn")
 	cfg.PGConnTimeout = viper.GetInt("pg.conntimeout")
 	cfg.PGCache = viper.GetBool("pg.cache")
+	cfg.PGCacheSize = viper.GetInt("pg.cachesize")
+	cfg.BatchMaxSize = viper.GetInt("batch.maxsize")
+	cfg.BatchMaxOperations = viper.GetInt("batch.maxoperations")
+	cfg.PGReplicaURLs = viper.GetStringSlice("pg.replicas")
 	cfg.SingleDB = viper.GetBool("pg.single")
 }
 
@@ -492,9 +657,17 @@ func parseAuthConfig(cfg *Prest) {
 func parseHTTPConfig(cfg *Prest) {
 	cfg.HTTPHost = viper.GetString("http.host")
 	cfg.HTTPPort = viper.GetInt("http.port")
+	cfg.HTTPUnixSocket = viper.GetString("http.unixsocket")
 	cfg.HTTPTimeout = viper.GetInt("http.timeout")
+	cfg.RequestTimeout = viper.GetInt("http.requesttimeout")
+	cfg.HTTPReadHeaderTimeout = viper.GetInt("http.readheadertimeout")
+	cfg.HTTPReadTimeout = viper.GetInt("http.readtimeout")
+	cfg.HTTPWriteTimeout = viper.GetInt("http.writetimeout")
+	cfg.HTTPIdleTimeout = viper.GetInt("http.idletimeout")
 
 	cfg.HTTPSMode = viper.GetBool("https.mode")
 	cfg.HTTPSCert = viper.GetString("https.cert")
 	cfg.HTTPSKey = viper.GetString("https.key")
+	cfg.TLSMinVersion = viper.GetString("https.minversion")
+	cfg.TLSCipherSuites = viper.GetStringSlice("https.ciphersuites")
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -23,6 +24,40 @@ func TestLoad(t *testing.T) {
 	require.Equal(t, 60, PrestConf.HTTPTimeout)
 }
 
+func TestParseHTTPServerTimeouts(t *testing.T) {
+	t.Setenv("PREST_CONF", "../notfound.toml")
+	viperCfg()
+	cfg := &Prest{}
+	Parse(cfg)
+	require.Equal(t, 10, cfg.HTTPReadHeaderTimeout)
+	require.Equal(t, 30, cfg.HTTPReadTimeout)
+	require.Equal(t, 90, cfg.HTTPWriteTimeout)
+	require.Equal(t, 120, cfg.HTTPIdleTimeout)
+
+	t.Setenv("PREST_HTTP_READTIMEOUT", "5")
+	t.Setenv("PREST_HTTP_WRITETIMEOUT", "0")
+	viperCfg()
+	cfg = &Prest{}
+	Parse(cfg)
+	require.Equal(t, 5, cfg.HTTPReadTimeout)
+	require.Equal(t, 0, cfg.HTTPWriteTimeout)
+}
+
+func TestParseTLSConfig(t *testing.T) {
+	t.Setenv("PREST_CONF", "../notfound.toml")
+	viperCfg()
+	cfg := &Prest{}
+	Parse(cfg)
+	require.Equal(t, "1.2", cfg.TLSMinVersion)
+	require.Empty(t, cfg.TLSCipherSuites)
+
+	t.Setenv("PREST_HTTPS_MINVERSION", "1.3")
+	viperCfg()
+	cfg = &Prest{}
+	Parse(cfg)
+	require.Equal(t, "1.3", cfg.TLSMinVersion)
+}
+
 func TestParse(t *testing.T) {
 	t.Run("no envs", func(t *testing.T) {
 		t.Setenv("PREST_CONF", "../notfound.toml")
@@ -35,6 +70,7 @@ func TestParse(t *testing.T) {
 		require.Equal(t, "postgres", cf.PGUser)
 		require.Equal(t, "postgres", cf.PGPass)
 		require.Equal(t, true, cf.PGCache)
+		require.Equal(t, 500, cf.PGCacheSize)
 		require.Equal(t, true, cf.SingleDB)
 		require.Equal(t, "disable", cf.PGSSLMode)
 		require.Equal(t, false, cf.Debug)
@@ -231,6 +267,19 @@ func TestHTTPPort(t *testing.T) {
 		Parse(cfg)
 		require.Equal(t, 8080, cfg.HTTPPort)
 	})
+
+	for _, port := range []string{"http", "0", "70000"} {
+		t.Run("invalid PORT "+port, func(t *testing.T) {
+			os.Unsetenv("PREST_HTTP_PORT")
+
+			t.Setenv("PORT", port)
+			t.Setenv("PREST_HTTP_PORT", "3030")
+			viperCfg()
+			cfg := &Prest{}
+			Parse(cfg)
+			require.Equal(t, 3030, cfg.HTTPPort)
+		})
+	}
 }
 
 func Test_parseDatabaseURL(t *testing.T) {
@@ -331,3 +380,60 @@ func Test_ExposeDataConfig(t *testing.T) {
 		require.Equal(t, metadata[i], v)
 	}
 }
+
+func TestReload(t *testing.T) {
+	defer SetReadOnly(false)
+	file := filepath.Join(t.TempDir(), "reload.toml")
+	require.NoError(t, os.WriteFile(file, []byte("readonly = true\n"), 0600))
+	t.Setenv("PREST_CONF", file)
+	viperCfg()
+	require.False(t, IsReadOnly())
+
+	require.NoError(t, Reload())
+	require.True(t, IsReadOnly())
+
+	require.NoError(t, os.WriteFile(file, []byte("readonly = false\n"), 0600))
+	require.NoError(t, Reload())
+	require.False(t, IsReadOnly())
+}
+
+func TestReloadPinned(t *testing.T) {
+	defer SetReadOnly(false)
+	defer PinReadOnly(false)
+	file := filepath.Join(t.TempDir(), "pinned.toml")
+	require.NoError(t, os.WriteFile(file, []byte("readonly = false\n"), 0600))
+	t.Setenv("PREST_CONF", file)
+	viperCfg()
+
+	PinReadOnly(true)
+	require.True(t, IsReadOnly())
+	require.NoError(t, Reload())
+	require.True(t, IsReadOnly(), "the config file can't turn off a pinned read-only mode")
+
+	PinReadOnly(false)
+	require.NoError(t, Reload())
+	require.False(t, IsReadOnly())
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "apikeys.toml")
+	require.NoError(t, os.WriteFile(file, []byte(`[apikey]
+enabled = true
+
+[[apikey.keys]]
+name = "billing"
+hash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+role = "billing"
+`), 0600))
+	t.Setenv("PREST_CONF", file)
+	viperCfg()
+
+	cfg := &Prest{}
+	Parse(cfg)
+	require.True(t, cfg.APIKeyEnabled)
+	require.Equal(t, []APIKeyConf{{
+		Name: "billing",
+		Hash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Role: "billing",
+	}}, cfg.APIKeys)
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+// readOnly is the live read-only mode, Prest.ReadOnly only holds its value
+// at startup as it can change on Reload
+var readOnly atomic.Bool
+
+// readOnlyPinned keeps read-only mode on across reloads, see PinReadOnly
+var readOnlyPinned atomic.Bool
+
+// PinReadOnly keeps read-only mode on whatever the config file says on
+// Reload, as the --read-only flag does
+func PinReadOnly(pinned bool) {
+	readOnlyPinned.Store(pinned)
+	if pinned {
+		SetReadOnly(true)
+	}
+}
+
+// IsReadOnly tells whether writes are rejected
+func IsReadOnly() bool {
+	return readOnly.Load()
+}
+
+// SetReadOnly turns read-only mode on or off, logging when it changes
+func SetReadOnly(enabled bool) {
+	if readOnly.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		slog.Warn("read-only mode is active, writes are rejected")
+		return
+	}
+	slog.Info("read-only mode is off, writes are accepted")
+}
+
+// Reload re-reads the config file and applies the settings that can change
+// without a restart, currently only read-only mode unless it is pinned
+func Reload() error {
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return err
+		}
+	}
+	SetReadOnly(readOnlyPinned.Load() || viper.GetBool("readonly"))
+	return nil
+}
@@ -7,4 +7,10 @@ const (
 	DBNameKey
 	HTTPTimeoutKey
 	UserInfoKey
+	RouteKey
+	RequestIDKey
+	UsePrimaryKey
+	ClaimsKey
+	APIKeyKey
+	AuditPrincipalKey
 )
@@ -0,0 +1,10 @@
+package context
+
+import "context"
+
+// RequestIDFromContext returns the request id set by the request id
+// middleware, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
@@ -57,6 +57,8 @@ func Token(u auth.User) (t string, err error) {
 
 	cl := auth.Claims{
 		UserInfo:  u,
+		Issuer:    config.PrestConf.JWTIssuer,
+		Audience:  config.PrestConf.JWTAudience,
 		NotBefore: jwt.NewNumericDate(getToken),
 		Expiry:    jwt.NewNumericDate(expireToken),
 	}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+	"github.com/prest/prest/v2/internal/ident"
+	"github.com/prest/prest/v2/middlewares/statements"
+)
+
+// batchOperation is one operation of a batch request, query holds the same
+// parameters the table endpoints take in the URL (filters, _returning)
+type batchOperation struct {
+	Action string            `json:"action"`
+	Schema string            `json:"schema"`
+	Table  string            `json:"table"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Query  map[string]string `json:"query,omitempty"`
+}
+
+// Batch runs an ordered list of insert, update and delete operations on the
+// default database in a single transaction, any failure rolls back all of them
+func Batch(w http.ResponseWriter, r *http.Request) {
+	var operations []batchOperation
+	if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+		jsonError(w, fmt.Sprintf("could not perform Batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if len(operations) == 0 {
+		jsonError(w, "could not perform Batch: no operations", http.StatusBadRequest)
+		return
+	}
+	if max := config.PrestConf.BatchMaxOperations; max > 0 && len(operations) > max {
+		jsonError(w, fmt.Sprintf("could not perform Batch: %d operations, max %d", len(operations), max), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var userName string
+	if user, ok := r.Context().Value(pctx.UserInfoKey).(auth.User); ok {
+		userName = user.Username
+	}
+
+	database := config.PrestConf.Adapter.GetDatabase()
+	ctx := context.WithValue(r.Context(), pctx.DBNameKey, database)
+
+	timeout, _ := ctx.Value(pctx.HTTPTimeoutKey).(int)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(timeout))
+	defer cancel()
+
+	// build every statement before opening the transaction
+	type statement struct {
+		action    string
+		sql       string
+		values    []interface{}
+		doNothing bool
+	}
+	stmts := make([]statement, 0, len(operations))
+	for i, op := range operations {
+		if !ident.IsSegment(op.Schema) || !ident.IsSegment(op.Table) {
+			jsonError(w, fmt.Sprintf("operation %d: invalid identifier", i), http.StatusBadRequest)
+			return
+		}
+		permission, ok := batchPermissions[strings.ToLower(op.Action)]
+		if !ok {
+			jsonError(w, fmt.Sprintf("operation %d: invalid action %q", i, op.Action), http.StatusBadRequest)
+			return
+		}
+		if !config.PrestConf.Adapter.TablePermissions(op.Table, permission, userName) {
+			jsonError(w, fmt.Sprintf("operation %d: authorization required", i), http.StatusUnauthorized)
+			return
+		}
+		SQL, values, doNothing, err := batchSQL(ctx, database, op)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("operation %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		stmts = append(stmts, statement{strings.ToLower(op.Action), SQL, values, doNothing})
+	}
+
+	tx, err := config.PrestConf.Adapter.GetTransactionCtx(ctx)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("could not perform Batch: %v", err), http.StatusInternalServerError)
+		return
+	}
+	results := make([]json.RawMessage, 0, len(stmts))
+	for i, stmt := range stmts {
+		sc := batchExec(tx, stmt.action, stmt.sql, stmt.values)
+		if err = sc.Err(); skippedConflict(err, stmt.doNothing) {
+			results = append(results, json.RawMessage("{}"))
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			queryError(w, r, fmt.Sprintf("operation %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		results = append(results, sc.Bytes())
+	}
+	if err = tx.Commit(); err != nil {
+		jsonError(w, fmt.Sprintf("could not perform Batch: %v", err), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+var batchPermissions = map[string]string{
+	"insert": statements.WRITE,
+	"update": statements.WRITE,
+	"delete": statements.DELETE,
+}
+
+// batchSQL builds the statement of op with the adapter parsers used by the
+// table endpoints, from a request carrying op's body and query. doNothing
+// tells that the insert skips conflicting rows
+func batchSQL(ctx context.Context, database string, op batchOperation) (SQL string, values []interface{}, doNothing bool, err error) {
+	query := url.Values{}
+	for key, value := range op.Query {
+		query.Set(key, value)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/?"+query.Encode(), bytes.NewReader(op.Body))
+	if err != nil {
+		return
+	}
+	adapter := config.PrestConf.Adapter
+
+	switch strings.ToLower(op.Action) {
+	case "insert":
+		var names, placeholders, conflictSyntax string
+		names, placeholders, values, err = adapter.ParseInsertRequest(r)
+		if err != nil {
+			return
+		}
+		conflictSyntax, doNothing, err = adapter.OnConflictByRequest(r, names)
+		if err != nil {
+			return
+		}
+		SQL = adapter.InsertSQL(database, op.Schema, op.Table, names, placeholders) + conflictSyntax
+		return
+	case "update":
+		var setSyntax, where string
+		var whereValues []interface{}
+		setSyntax, values, err = adapter.SetByRequest(r, 1)
+		if err != nil {
+			return
+		}
+		where, whereValues, err = adapter.WhereByRequest(r, len(values)+1)
+		if err != nil {
+			return
+		}
+		SQL = adapter.UpdateSQL(database, op.Schema, op.Table, setSyntax)
+		if where != "" {
+			SQL = fmt.Sprint(SQL, " WHERE ", where)
+			values = append(values, whereValues...)
+		}
+	case "delete":
+		var where string
+		where, values, err = adapter.WhereByRequest(r, 1)
+		if err != nil {
+			return
+		}
+		var softDelete string
+		softDelete, err = softDeleteColumn(op.Schema, op.Table)
+		if err != nil {
+			return
+		}
+		if softDelete != "" {
+			SQL = softDeleteSQL(database, op.Schema, op.Table, softDelete, where)
+			break
+		}
+		SQL = adapter.DeleteSQL(database, op.Schema, op.Table)
+		if where != "" {
+			SQL = fmt.Sprint(SQL, " WHERE ", where)
+		}
+	}
+
+	returningSyntax, err := adapter.ReturningByRequest(r)
+	if err != nil {
+		return
+	}
+	if returningSyntax != "" {
+		SQL = fmt.Sprint(SQL, " RETURNING ", returningSyntax)
+	}
+	return
+}
+
+func batchExec(tx *sql.Tx, action, SQL string, values []interface{}) adapters.Scanner {
+	switch action {
+	case "insert":
+		return config.PrestConf.Adapter.InsertWithTransaction(tx, SQL, values...)
+	case "update":
+		return config.PrestConf.Adapter.UpdateWithTransaction(tx, SQL, values...)
+	default:
+		return config.PrestConf.Adapter.DeleteWithTransaction(tx, SQL, values...)
+	}
+}
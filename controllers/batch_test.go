@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/testutils"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBatch(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/batch", setHTTPTimeoutMiddleware(Batch)).Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	insert := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"action": "insert", "schema": "public", "table": "test4",
+			"body": map[string]interface{}{"name": name},
+		}
+	}
+
+	var testCases = []struct {
+		description string
+		request     interface{}
+		status      int
+		body        []string
+	}{
+		{"insert, update and delete in one transaction", []interface{}{
+			insert("batch-a"),
+			map[string]interface{}{
+				"action": "update", "schema": "public", "table": "test4",
+				"body":  map[string]interface{}{"name": "batch-b"},
+				"query": map[string]string{"name": "batch-a", "_returning": "name"},
+			},
+			map[string]interface{}{
+				"action": "delete", "schema": "public", "table": "test4",
+				"query": map[string]string{"name": "batch-b"},
+			},
+		}, http.StatusOK, []string{`"name":"batch-a"`, `"name":"batch-b"`, `"rows_affected":1`}},
+		{"a failing operation rolls back the others", []interface{}{insert("batch-c"), insert("batch-c")}, http.StatusBadRequest, []string{"operation 1"}},
+		{"the rolled back row can be inserted again", []interface{}{insert("batch-c")}, http.StatusOK, []string{`"name":"batch-c"`}},
+		{"invalid action", []interface{}{map[string]interface{}{"action": "drop", "schema": "public", "table": "test4"}}, http.StatusBadRequest, []string{"invalid action"}},
+		{"invalid table", []interface{}{map[string]interface{}{"action": "delete", "schema": "public", "table": "test4;"}}, http.StatusBadRequest, []string{"invalid identifier"}},
+		{"invalid column", []interface{}{map[string]interface{}{"action": "insert", "schema": "public", "table": "test4", "body": map[string]interface{}{"name;": "x"}}}, http.StatusBadRequest, nil},
+		{"no operations", []interface{}{}, http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Log(tc.description)
+		testutils.DoRequest(t, server.URL+"/batch", tc.request, "POST", tc.status, "Batch", tc.body...)
+	}
+
+	t.Run("too many operations", func(t *testing.T) {
+		defer func(max int) { config.PrestConf.BatchMaxOperations = max }(config.PrestConf.BatchMaxOperations)
+		config.PrestConf.BatchMaxOperations = 1
+		testutils.DoRequest(t, server.URL+"/batch", []interface{}{insert("batch-d"), insert("batch-e")}, "POST", http.StatusRequestEntityTooLarge, "Batch")
+	})
+}
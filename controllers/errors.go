@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -18,3 +19,13 @@ const (
 func jsonError(writer http.ResponseWriter, message string, status int) {
 	http.Error(writer, fmt.Sprintf(jsonErrorMsg, message), status)
 }
+
+// queryError reports a failed query, when the request deadline set by
+// http.requesttimeout canceled it the client gets a 503 instead of status
+func queryError(writer http.ResponseWriter, r *http.Request, message string, status int) {
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		jsonError(writer, "request timeout", http.StatusServiceUnavailable)
+		return
+	}
+	jsonError(writer, message, status)
+}
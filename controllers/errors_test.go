@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	queryError(rec, httptest.NewRequest(http.MethodGet, "/", nil), "syntax error", http.StatusBadRequest)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.JSONEq(t, `{"error":"syntax error"}`, rec.Body.String())
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	rec = httptest.NewRecorder()
+	queryError(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), "pq: canceling statement due to user request", http.StatusBadRequest)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"error":"request timeout"}`, rec.Body.String())
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+
+	"github.com/prest/prest/v2/config"
+)
+
+// limitKey sets the LIMIT of a select without _page, it may raise the
+// implicit config.PrestConf.GuardMaxRows limit up to the cap of rowCap
+const limitKey = "_limit"
+
+// rowCap is the largest _limit and _page_size accepted, GuardLimitCap or
+// else GuardMaxRows, 0 accepts any
+func rowCap() int {
+	if config.PrestConf.GuardLimitCap > 0 {
+		return config.PrestConf.GuardLimitCap
+	}
+	return config.PrestConf.GuardMaxRows
+}
+
+// rowLimit returns the LIMIT clause of a select, empty when page already
+// bounds it or no limit applies
+func rowLimit(queries url.Values, page string) (string, error) {
+	limitCap := rowCap()
+	if page != "" {
+		if size, err := strconv.Atoi(queries.Get("_page_size")); err == nil && limitCap > 0 && size > limitCap {
+			return "", fmt.Errorf("_page_size %d exceeds the maximum of %d rows", size, limitCap)
+		}
+		return "", nil
+	}
+	limit := config.PrestConf.GuardMaxRows
+	if l := queries.Get(limitKey); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("invalid %s: %q", limitKey, l)
+		}
+		if limitCap > 0 && n > limitCap {
+			return "", fmt.Errorf("%s %d exceeds the maximum of %d rows", limitKey, n, limitCap)
+		}
+		limit = n
+	}
+	if limit == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("LIMIT %d", limit), nil
+}
+
+// checkCost rejects sqlSelect when the planner estimates it above
+// config.PrestConf.GuardMaxCost. A query the planner can't explain is let
+// through, running it reports the same error
+func checkCost(ctx context.Context, sqlSelect string, values []interface{}) error {
+	maxCost := config.PrestConf.GuardMaxCost
+	if maxCost <= 0 {
+		return nil
+	}
+	cost, err := config.PrestConf.Adapter.ExplainCostCtx(ctx, sqlSelect, values...)
+	if err != nil {
+		slog.Debug("could not explain query", "err", err)
+		return nil
+	}
+	if cost > maxCost {
+		return fmt.Errorf("query estimated cost %.2f exceeds the maximum of %.2f", cost, maxCost)
+	}
+	return nil
+}
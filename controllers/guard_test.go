@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/testutils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowLimit(t *testing.T) {
+	defer func(maxRows, limitCap int) {
+		config.PrestConf.GuardMaxRows, config.PrestConf.GuardLimitCap = maxRows, limitCap
+	}(config.PrestConf.GuardMaxRows, config.PrestConf.GuardLimitCap)
+
+	var testCases = []struct {
+		description string
+		maxRows     int
+		limitCap    int
+		query       string
+		page        string
+		expected    string
+		err         string
+	}{
+		{"no guardrails", 0, 0, "", "", "", ""},
+		{"request limit without guardrails", 0, 0, "_limit=5", "", "LIMIT 5", ""},
+		{"implicit limit", 100, 0, "", "", "LIMIT 100", ""},
+		{"lower limit", 100, 0, "_limit=5", "", "LIMIT 5", ""},
+		{"limit above max rows without cap", 100, 0, "_limit=500", "", "", "exceeds the maximum of 100 rows"},
+		{"limit raised up to the cap", 100, 1000, "_limit=500", "", "LIMIT 500", ""},
+		{"limit above the cap", 100, 1000, "_limit=5000", "", "", "exceeds the maximum of 1000 rows"},
+		{"invalid limit", 100, 0, "_limit=ten", "", "", "invalid _limit"},
+		{"negative limit", 100, 0, "_limit=-1", "", "", "invalid _limit"},
+		{"paginated", 100, 0, "_page=1&_page_size=50", "LIMIT 50 OFFSET(1 - 1) * 50", "", ""},
+		{"page size above the cap", 100, 0, "_page=1&_page_size=500", "LIMIT 500 OFFSET(1 - 1) * 500", "", "_page_size 500 exceeds"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config.PrestConf.GuardMaxRows, config.PrestConf.GuardLimitCap = tc.maxRows, tc.limitCap
+			queries, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			limit, err := rowLimit(queries, tc.page)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, limit)
+		})
+	}
+}
+
+func TestSelectFromTablesGuard(t *testing.T) {
+	defer func(maxRows int, maxCost float64) {
+		config.PrestConf.GuardMaxRows, config.PrestConf.GuardMaxCost = maxRows, maxCost
+	}(config.PrestConf.GuardMaxRows, config.PrestConf.GuardMaxCost)
+	config.PrestConf.GuardMaxRows = 2
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{database}/{schema}/{table}", setHTTPTimeoutMiddleware(SelectFromTables)).
+		Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/prest-test/public/test_group_by_table")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var rows []map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rows))
+	require.Len(t, rows, 2, "unbounded select limited to GuardMaxRows")
+
+	testutils.DoRequest(t, server.URL+"/prest-test/public/test_group_by_table?_limit=1", nil, "GET", http.StatusOK, "SelectFromTables", `"name"`)
+	testutils.DoRequest(t, server.URL+"/prest-test/public/test_group_by_table?_limit=3", nil, "GET", http.StatusBadRequest, "SelectFromTables", "exceeds the maximum")
+
+	config.PrestConf.GuardMaxCost = 0.01
+	testutils.DoRequest(t, server.URL+"/prest-test/public/test_group_by_table", nil, "GET", http.StatusBadRequest, "SelectFromTables", "estimated cost")
+}
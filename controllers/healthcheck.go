@@ -42,3 +42,27 @@ func WrappedHealthCheck(checks CheckList) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// Liveness reports that the process is up, it never touches the database
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// WrappedReadinessCheck runs the checks with the given timeout and answers
+// 200 when all of them pass or 503 otherwise. Unlike WrappedHealthCheck it
+// doesn't rely on the request timeout set by the middleware stack, so it can
+// be served outside of it
+func WrappedReadinessCheck(checks CheckList, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		for _, check := range checks {
+			if err := check(ctx); err != nil {
+				slog.Error("readiness check failed", "err", err)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
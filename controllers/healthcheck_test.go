@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/prest/prest/v2/testutils"
 
@@ -37,3 +38,29 @@ func TestHealthStatus(t *testing.T) {
 		testutils.DoRequest(t, server.URL+"/_health", nil, "GET", tc.expected, "")
 	}
 }
+
+func TestLiveness(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(Liveness))
+	defer server.Close()
+	testutils.DoRequest(t, server.URL, nil, "GET", http.StatusOK, "")
+}
+
+func TestReadinessStatus(t *testing.T) {
+	slowDB := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	for _, tc := range []struct {
+		check    func(context.Context) error
+		desc     string
+		expected int
+	}{
+		{healthyDB, "healthy database", http.StatusOK},
+		{unhealthyDB, "unhealthy database", http.StatusServiceUnavailable},
+		{slowDB, "database ping timeout", http.StatusServiceUnavailable},
+	} {
+		server := httptest.NewServer(WrappedReadinessCheck(CheckList{tc.check}, 10*time.Millisecond))
+		testutils.DoRequest(t, server.URL, nil, "GET", tc.expected, tc.desc)
+		server.Close()
+	}
+}
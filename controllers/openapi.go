@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prest/prest/v2/adapters/postgres/statements"
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+	permissions "github.com/prest/prest/v2/middlewares/statements"
+)
+
+// column is a row of statements.Columns
+type column struct {
+	Schema     string `json:"schema"`
+	Table      string `json:"table"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primary_key"`
+}
+
+// listParameters are the query parameters accepted by a table GET
+var listParameters = []map[string]any{
+	queryParameter("_select", "comma separated columns to return"),
+	queryParameter("_order", "comma separated columns to order by, prefix with - to sort descending"),
+	queryParameter("_page", "page number"),
+	queryParameter("_page_size", "rows per page"),
+	queryParameter("_limit", "maximum rows to return without _page"),
+	queryParameter("_paginate", "offset or keyset pagination"),
+	queryParameter("_keyset", "column keyset pages are sorted by, prefix with - to sort descending"),
+	queryParameter("_cursor", "X-Next-Cursor of the previous keyset page"),
+	queryParameter("_with_deleted", "true to list soft-deleted rows too"),
+	queryParameter("_count", "column to count, * counts the rows"),
+	queryParameter("_distinct", "true to return distinct rows"),
+	queryParameter("_groupby", "comma separated columns to group by"),
+}
+
+// OpenAPI describes the table endpoints of the default database as an
+// OpenAPI 3 document, introspected from the live schema
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	timeout, _ := r.Context().Value(pctx.HTTPTimeoutKey).(int)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*time.Duration(timeout))
+	defer cancel()
+
+	sc := config.PrestConf.Adapter.QueryCtx(ctx, statements.Columns)
+	if err := sc.Err(); err != nil {
+		jsonError(w, fmt.Sprintf("could not introspect schema: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var columns []column
+	if err := json.Unmarshal(sc.Bytes(), &columns); err != nil {
+		jsonError(w, fmt.Sprintf("could not introspect schema: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openAPIDocument(config.PrestConf.Adapter.GetDatabase(), columns))
+}
+
+// openAPIDocument builds the document of the tables of database, leaving out
+// those restricted by the access configuration
+func openAPIDocument(database string, columns []column) map[string]any {
+	paths := map[string]any{}
+	schemas := map[string]any{}
+	for len(columns) > 0 {
+		n := 1
+		for n < len(columns) && columns[n].Schema == columns[0].Schema && columns[n].Table == columns[0].Table {
+			n++
+		}
+		table := columns[:n]
+		columns = columns[n:]
+
+		operations := tableOperations(database, table)
+		if len(operations) == 0 {
+			continue
+		}
+		schemas[table[0].Schema+"."+table[0].Table] = rowSchema(table)
+		paths[fmt.Sprintf("/%s/%s/%s", database, table[0].Schema, table[0].Table)] = operations
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "prestd " + database,
+			"version": "1.0.0",
+		},
+		"servers":    []map[string]any{{"url": "/" + strings.Trim(config.PrestConf.ContextPath, "/")}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// tableOperations returns the operations allowed on table
+func tableOperations(database string, table []column) map[string]any {
+	name := table[0].Table
+	ref := map[string]any{"$ref": "#/components/schemas/" + table[0].Schema + "." + name}
+	filters := make([]map[string]any, 0, len(table))
+	for _, col := range table {
+		filters = append(filters, queryParameter(col.Name, "filter by value, or by $op.value e.g. $gt.10"))
+	}
+	operations := map[string]any{}
+	if config.PrestConf.Adapter.TablePermissions(name, permissions.READ, "") {
+		operations["get"] = map[string]any{
+			"summary":    "List " + name,
+			"parameters": append(append([]map[string]any{}, listParameters...), filters...),
+			"responses": map[string]any{
+				"200": jsonResponse("rows", map[string]any{"type": "array", "items": ref}),
+			},
+		}
+	}
+	if config.PrestConf.Adapter.TablePermissions(name, permissions.WRITE, "") {
+		body := map[string]any{"oneOf": []any{ref, map[string]any{"type": "array", "items": ref}}}
+		operations["post"] = map[string]any{
+			"summary":     "Insert into " + name,
+			"requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": body}}},
+			"responses": map[string]any{
+				"201": jsonResponse("inserted row", ref),
+			},
+		}
+		operations["patch"] = map[string]any{
+			"summary":     "Update " + name,
+			"parameters":  filters,
+			"requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": ref}}},
+			"responses": map[string]any{
+				"200": jsonResponse("rows affected", rowsAffected),
+			},
+		}
+	}
+	if config.PrestConf.Adapter.TablePermissions(name, permissions.DELETE, "") {
+		operations["delete"] = map[string]any{
+			"summary":    "Delete from " + name,
+			"parameters": filters,
+			"responses": map[string]any{
+				"200": jsonResponse("rows affected", rowsAffected),
+			},
+		}
+	}
+	return operations
+}
+
+var rowsAffected = map[string]any{
+	"type":       "object",
+	"properties": map[string]any{"rows_affected": map[string]any{"type": "integer"}},
+}
+
+func rowSchema(table []column) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, col := range table {
+		property := jsonSchemaType(col.Type)
+		if col.Nullable {
+			property["nullable"] = true
+		}
+		if col.PrimaryKey {
+			property["description"] = "primary key"
+		}
+		properties[col.Name] = property
+		if !col.Nullable {
+			required = append(required, col.Name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonSchemaType maps a PostgreSQL data_type to its JSON schema
+func jsonSchemaType(dataType string) map[string]any {
+	switch dataType {
+	case "smallint", "integer", "bigint":
+		return map[string]any{"type": "integer"}
+	case "numeric", "real", "double precision":
+		return map[string]any{"type": "number"}
+	case "boolean":
+		return map[string]any{"type": "boolean"}
+	case "json", "jsonb":
+		return map[string]any{}
+	case "ARRAY":
+		return map[string]any{"type": "array", "items": map[string]any{}}
+	case "date":
+		return map[string]any{"type": "string", "format": "date"}
+	case "timestamp without time zone", "timestamp with time zone":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "uuid":
+		return map[string]any{"type": "string", "format": "uuid"}
+	}
+	return map[string]any{"type": "string"}
+}
+
+func queryParameter(name, description string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]any{"type": "string"},
+	}
+}
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+// swaggerUI loads Swagger UI from a CDN pointed at the OpenAPI document
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+<title>prestd API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "_openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// SwaggerUI renders the OpenAPI document with Swagger UI
+func SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUI))
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	defer func(access config.AccessConf) { config.PrestConf.AccessConf = access }(config.PrestConf.AccessConf)
+	config.PrestConf.AccessConf = config.AccessConf{
+		Restrict: true,
+		Tables: []config.TablesConf{
+			{Name: "books", Permissions: []string{"read"}},
+		},
+	}
+
+	columns := []column{
+		{Schema: "public", Table: "books", Name: "id", Type: "integer", PrimaryKey: true},
+		{Schema: "public", Table: "books", Name: "title", Type: "text", Nullable: true},
+		{Schema: "public", Table: "secrets", Name: "value", Type: "text"},
+	}
+	doc := openAPIDocument("prest", columns)
+
+	require.Equal(t, "3.0.3", doc["openapi"])
+	paths := doc["paths"].(map[string]any)
+	require.Len(t, paths, 1, "tables without permissions are left out")
+	books := paths["/prest/public/books"].(map[string]any)
+	require.Contains(t, books, "get")
+	require.NotContains(t, books, "post")
+	require.NotContains(t, books, "delete")
+
+	schema := doc["components"].(map[string]any)["schemas"].(map[string]any)["public.books"].(map[string]any)
+	require.Equal(t, []string{"id"}, schema["required"])
+	properties := schema["properties"].(map[string]any)
+	require.Equal(t, "integer", properties["id"].(map[string]any)["type"])
+	require.Equal(t, true, properties["title"].(map[string]any)["nullable"])
+}
+
+func TestOpenAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		OpenAPI(w, r.WithContext(context.WithValue(r.Context(), pctx.HTTPTimeoutKey, 60)))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	require.Contains(t, doc.Paths, "/prest-test/public/test")
+}
+
+func TestSwaggerUI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SwaggerUI(rec, httptest.NewRequest(http.MethodGet, "/_openapi", nil))
+	require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), `url: "_openapi.json"`)
+}
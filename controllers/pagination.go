@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/template"
+)
+
+const (
+	// totalCountKey asks SelectFromTables for the X-Total-Count and Link
+	// headers, _count is taken by the COUNT() projection
+	totalCountKey   = "_total"
+	defaultPageSize = 10
+
+	// paginateKey picks the pagination of a select, offset (_page) or
+	// keyset (_keyset and _cursor), overriding config.PrestConf.PaginationMode
+	paginateKey = "_paginate"
+	// keysetKey is the column keyset pages are sorted by, -column sorts
+	// descending
+	keysetKey = "_keyset"
+	// cursorKey is the keyset value of the last row of the previous page
+	cursorKey = "_cursor"
+	// nextCursorHeader carries the _cursor of the next keyset page
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+// paginationMode returns the pagination requested by queries, "offset" or
+// "keyset"
+func paginationMode(queries url.Values) (string, error) {
+	mode := queries.Get(paginateKey)
+	if mode == "" {
+		mode = config.PrestConf.PaginationMode
+	}
+	switch mode {
+	case "", "offset":
+		return "offset", nil
+	case "keyset":
+		return "keyset", nil
+	}
+	return "", fmt.Errorf("invalid %s %q, expected offset or keyset", paginateKey, mode)
+}
+
+// keysetPage is a page of keyset pagination, where is empty on the first
+// page and its value is numbered by the placeholder given to
+// keysetPagination
+type keysetPage struct {
+	column string
+	where  string
+	values []interface{}
+	order  string
+	size   int
+}
+
+// keysetPagination builds the page of rows following _cursor in the order of
+// the _keyset column, placeholder is the number of the cursor value
+func keysetPagination(queries url.Values, placeholder int) (page keysetPage, err error) {
+	column := queries.Get(keysetKey)
+	if column == "" {
+		return page, fmt.Errorf("keyset pagination requires %s", keysetKey)
+	}
+	direction := "asc"
+	if strings.HasPrefix(column, "-") {
+		column, direction = column[1:], "desc"
+	}
+	k, err := template.Keyset(column, direction)
+	if err != nil {
+		return page, err
+	}
+	size := strconv.Itoa(defaultPageSize)
+	if s := queries.Get("_page_size"); s != "" {
+		size = s
+	}
+	if _, page.size, err = template.PageBounds("1", size); err != nil {
+		return page, fmt.Errorf("invalid _page_size %q", size)
+	}
+	segments := strings.Split(column, ".")
+	page.column = segments[len(segments)-1]
+	if cursor := queries.Get(cursorKey); cursor != "" {
+		page.where = k.After(fmt.Sprintf("$%d", placeholder))
+		page.values = []interface{}{cursor}
+	}
+	page.order = k.OrderBy()
+	return page, nil
+}
+
+// nextCursor returns the keyset value of the last row of a full page, empty
+// when it is the last page or the rows don't hold the keyset column
+func nextCursor(body []byte, column string, size int) string {
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) < size || len(rows) == 0 {
+		return ""
+	}
+	raw, ok := rows[len(rows)-1][column]
+	if !ok || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// totalCountSQL counts the rows of sqlSelect, the query before ordering and
+// pagination, so it takes the same WHERE values
+func totalCountSQL(sqlSelect string) string {
+	return fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS prest_total", sqlSelect)
+}
+
+// setPaginationHeaders sets X-Total-Count and, for paginated requests, an
+// RFC 5988 Link header with the first, prev, next and last pages
+func setPaginationHeaders(w http.ResponseWriter, u *url.URL, total int64) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	q := u.Query()
+	if q.Get("_page") == "" {
+		return
+	}
+	size := strconv.Itoa(defaultPageSize)
+	if s := q.Get("_page_size"); s != "" {
+		size = s
+	}
+	page, pageSize, err := template.PageBounds(q.Get("_page"), size)
+	if err != nil {
+		return
+	}
+	last := int((total + int64(pageSize) - 1) / int64(pageSize))
+	if last < 1 {
+		last = 1
+	}
+
+	link := func(rel string, p int) string {
+		q.Set("_page", strconv.Itoa(p))
+		l := *u
+		l.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, l.String(), rel)
+	}
+	links := []string{link("first", 1)}
+	if page > 1 {
+		links = append(links, link("prev", min(page-1, last)))
+	}
+	if page < last {
+		links = append(links, link("next", page+1))
+	}
+	links = append(links, link("last", last))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
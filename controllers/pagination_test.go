@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/template"
+	"github.com/prest/prest/v2/testutils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPaginationHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		url   string
+		total int64
+		link  string
+	}{
+		{
+			name:  "no page",
+			url:   "/db/public/t?_total=true",
+			total: 42,
+		},
+		{
+			name:  "middle page",
+			url:   "/db/public/t?_page=2&_page_size=10&_total=true",
+			total: 42,
+			link: `</db/public/t?_page=1&_page_size=10&_total=true>; rel="first", ` +
+				`</db/public/t?_page=1&_page_size=10&_total=true>; rel="prev", ` +
+				`</db/public/t?_page=3&_page_size=10&_total=true>; rel="next", ` +
+				`</db/public/t?_page=5&_page_size=10&_total=true>; rel="last"`,
+		},
+		{
+			name:  "first page with default size",
+			url:   "/db/public/t?_page=1&name=ada&_total=true",
+			total: 10,
+			link: `</db/public/t?_page=1&_total=true&name=ada>; rel="first", ` +
+				`</db/public/t?_page=1&_total=true&name=ada>; rel="last"`,
+		},
+		{
+			name:  "empty result",
+			url:   "/db/public/t?_page=1&_total=true",
+			total: 0,
+			link:  `</db/public/t?_page=1&_total=true>; rel="first", </db/public/t?_page=1&_total=true>; rel="last"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			setPaginationHeaders(w, u, tc.total)
+			require.Equal(t, strconv.FormatInt(tc.total, 10), w.Header().Get("X-Total-Count"))
+			require.Equal(t, tc.link, w.Header().Get("Link"))
+		})
+	}
+}
+
+func TestTotalCountSQL(t *testing.T) {
+	require.Equal(t,
+		`SELECT COUNT(*) FROM (SELECT * FROM "db"."public"."t" WHERE "name" = $1) AS prest_total`,
+		totalCountSQL(`SELECT * FROM "db"."public"."t" WHERE "name" = $1`))
+}
+
+func TestPaginationMode(t *testing.T) {
+	defer func(mode string) { config.PrestConf.PaginationMode = mode }(config.PrestConf.PaginationMode)
+
+	for _, tc := range []struct {
+		name     string
+		config   string
+		query    string
+		expected string
+		err      string
+	}{
+		{"default", "", "", "offset", ""},
+		{"config", "keyset", "", "keyset", ""},
+		{"query overrides config", "keyset", "_paginate=offset", "offset", ""},
+		{"query", "offset", "_paginate=keyset", "keyset", ""},
+		{"invalid", "offset", "_paginate=cursor", "", "invalid _paginate"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.PrestConf.PaginationMode = tc.config
+			queries, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			mode, err := paginationMode(queries)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, mode)
+		})
+	}
+}
+
+func TestKeysetPagination(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		query    string
+		expected keysetPage
+		err      string
+	}{
+		{
+			name:     "first page",
+			query:    "_keyset=id",
+			expected: keysetPage{column: "id", order: `ORDER BY "id" ASC`, size: defaultPageSize},
+		},
+		{
+			name:  "next page",
+			query: "_keyset=id&_cursor=42&_page_size=5",
+			expected: keysetPage{column: "id", where: `"id" > $3`, values: []interface{}{"42"},
+				order: `ORDER BY "id" ASC`, size: 5},
+		},
+		{
+			name:  "descending qualified column",
+			query: "_keyset=-test.created_at&_cursor=2024-01-01",
+			expected: keysetPage{column: "created_at", where: `"test"."created_at" < $3`, values: []interface{}{"2024-01-01"},
+				order: `ORDER BY "test"."created_at" DESC`, size: defaultPageSize},
+		},
+		{name: "no column", query: "_cursor=42", err: "requires _keyset"},
+		{name: "invalid column", query: `_keyset=id" OR 1=1 --`, err: "invalid"},
+		{name: "invalid page size", query: "_keyset=id&_page_size=0", err: "invalid _page_size"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			queries, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			page, err := keysetPagination(queries, 3)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, page)
+		})
+	}
+}
+
+func TestKeysetPaginationMaxPageSize(t *testing.T) {
+	defer func(max int) { template.MaxPageSize = max }(template.MaxPageSize)
+	template.MaxPageSize = 50
+
+	page, err := keysetPagination(url.Values{keysetKey: {"id"}, "_page_size": {"1000"}}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 50, page.size)
+}
+
+func TestNextCursor(t *testing.T) {
+	rows := []byte(`[{"id":1,"name":"a"},{"id":2,"name":"b"}]`)
+	require.Equal(t, "2", nextCursor(rows, "id", 2))
+	require.Equal(t, "b", nextCursor(rows, "name", 2))
+	require.Empty(t, nextCursor(rows, "id", 3), "last page")
+	require.Empty(t, nextCursor(rows, "missing", 2))
+	require.Empty(t, nextCursor([]byte(`[{"id":null}]`), "id", 1))
+	require.Empty(t, nextCursor([]byte(`{"count":2}`), "id", 1))
+}
+
+func TestSelectFromTablesKeyset(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/{database}/{schema}/{table}", setHTTPTimeoutMiddleware(SelectFromTables)).
+		Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tableURL := server.URL + "/prest-test/public/test_group_by_table?_paginate=keyset&_keyset=id&_page_size=2&_total=true"
+	resp, err := http.Get(tableURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var rows []struct {
+		ID int `json:"id"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rows))
+	require.Len(t, rows, 2)
+	cursor := resp.Header.Get(nextCursorHeader)
+	require.Equal(t, strconv.Itoa(rows[1].ID), cursor)
+
+	next, err := http.Get(tableURL + "&_cursor=" + cursor)
+	require.NoError(t, err)
+	defer next.Body.Close()
+	require.Equal(t, http.StatusOK, next.StatusCode)
+	var nextRows []struct {
+		ID int `json:"id"`
+	}
+	require.NoError(t, json.NewDecoder(next.Body).Decode(&nextRows))
+	require.NotEmpty(t, nextRows)
+	require.Greater(t, nextRows[0].ID, rows[1].ID)
+	require.Equal(t, resp.Header.Get("X-Total-Count"), next.Header.Get("X-Total-Count"),
+		"the cursor doesn't change the total")
+
+	testutils.DoRequest(t, tableURL+"&_order=name", nil, "GET", http.StatusBadRequest, "SelectFromTables", "keyset")
+	testutils.DoRequest(t, server.URL+"/prest-test/public/test_group_by_table?_paginate=pages", nil, "GET", http.StatusBadRequest, "SelectFromTables", "invalid _paginate")
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prest/prest/v2/adapters/postgres"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/internal/ident"
+
+	"github.com/gorilla/websocket"
+)
+
+const realtimePingPeriod = 30 * time.Second
+
+var upgrader = websocket.Upgrader{CheckOrigin: checkOrigin}
+
+// Realtime upgrades to a websocket pushing, as JSON, the NOTIFY payloads of
+// the LISTEN channels given in ?channel=, e.g. /_REALTIME?channel=orders
+func Realtime(w http.ResponseWriter, r *http.Request) {
+	channels, err := realtimeChannels(r.URL.Query()["channel"])
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, err := postgres.Subscribe(channels, config.PrestConf.RealtimeBuffer)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("could not listen: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Close()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already answered the client
+		return
+	}
+	defer conn.Close()
+
+	// the client only sends control frames, reading handles them and
+	// notices when the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(realtimePingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case n, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(realtimePingPeriod))
+			if err := conn.WriteJSON(n); err != nil {
+				slog.Debug("realtime write", "err", err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(realtimePingPeriod)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// realtimeChannels validates the requested channels against ident and the
+// configured allow-list, dropping duplicates
+func realtimeChannels(requested []string) (channels []string, err error) {
+	for _, param := range requested {
+		for _, channel := range strings.Split(param, ",") {
+			if !ident.IsSegment(channel) {
+				return nil, fmt.Errorf("invalid channel %q", channel)
+			}
+			allowed := config.PrestConf.RealtimeChannels
+			if len(allowed) > 0 && !slices.Contains(allowed, channel) {
+				return nil, fmt.Errorf("channel not allowed: %s", channel)
+			}
+			if !slices.Contains(channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channel")
+	}
+	return
+}
+
+// checkOrigin accepts the CORS allowed origins besides same host requests
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if config.PrestConf.CORSEnabled {
+		for _, allowed := range config.PrestConf.CORSAllowOrigin {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prest/prest/v2/adapters/postgres"
+	"github.com/prest/prest/v2/config"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealtimeChannels(t *testing.T) {
+	defer func(allowed []string) { config.PrestConf.RealtimeChannels = allowed }(config.PrestConf.RealtimeChannels)
+
+	var testCases = []struct {
+		description string
+		allowed     []string
+		requested   []string
+		expected    []string
+		err         string
+	}{
+		{"single channel", nil, []string{"orders"}, []string{"orders"}, ""},
+		{"comma separated and repeated", nil, []string{"orders,users", "orders"}, []string{"orders", "users"}, ""},
+		{"no channel", nil, nil, nil, "no channel"},
+		{"invalid channel", nil, []string{`orders"; DROP TABLE test; --`}, nil, "invalid channel"},
+		{"allowed channel", []string{"orders"}, []string{"orders"}, []string{"orders"}, ""},
+		{"channel not allowed", []string{"orders"}, []string{"users"}, nil, "channel not allowed"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config.PrestConf.RealtimeChannels = tc.allowed
+			channels, err := realtimeChannels(tc.requested)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, channels)
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	defer func(enabled bool, origins []string) {
+		config.PrestConf.CORSEnabled, config.PrestConf.CORSAllowOrigin = enabled, origins
+	}(config.PrestConf.CORSEnabled, config.PrestConf.CORSAllowOrigin)
+	config.PrestConf.CORSEnabled = true
+	config.PrestConf.CORSAllowOrigin = []string{"https://app.example.com"}
+
+	for origin, expected := range map[string]bool{
+		"":                          true,
+		"http://prest.local":        true,
+		"https://app.example.com":   true,
+		"https://evil.example.com":  false,
+		"https://prest.local.evil/": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://prest.local/_REALTIME", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		require.Equal(t, expected, checkOrigin(r), origin)
+	}
+}
+
+func TestRealtime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(Realtime))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?channel=prest_realtime", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	db, err := postgres.Get()
+	require.NoError(t, err)
+	_, err = db.Exec(`NOTIFY prest_realtime, 'hello'`)
+	require.NoError(t, err)
+
+	var n postgres.Notification
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(t, conn.ReadJSON(&n))
+	require.Equal(t, postgres.Notification{Channel: "prest_realtime", Payload: "hello"}, n)
+}
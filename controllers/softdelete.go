@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/internal/ident"
+)
+
+// withDeletedKey lists the soft-deleted rows along with the others
+const withDeletedKey = "_with_deleted"
+
+// softDeleteColumn returns the column marking the deleted rows of
+// schema.table, empty when its rows are deleted for good. Only the tables
+// listed in softdelete.tables have the column, the others are left alone
+func softDeleteColumn(schema, table string) (string, error) {
+	if !config.PrestConf.SoftDeleteEnabled || !slices.Contains(config.PrestConf.SoftDeleteTables, schema+"."+table) {
+		return "", nil
+	}
+	column := config.PrestConf.SoftDeleteColumn
+	if !ident.IsSegment(column) {
+		return "", fmt.Errorf("invalid soft-delete column %q", column)
+	}
+	return column, nil
+}
+
+// notDeleted returns the condition leaving the soft-deleted rows of
+// schema.table out of a select, empty when there are none or the request
+// asks for them
+func notDeleted(schema, table string, queries url.Values) (string, error) {
+	if queries.Get(withDeletedKey) == "true" {
+		return "", nil
+	}
+	column, err := softDeleteColumn(schema, table)
+	if column == "" || err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%s"."%s" IS NULL`, table, column), nil
+}
+
+// softDeleteSQL marks the rows matching where as deleted instead of deleting
+// them, rows already deleted keep their deletion time
+func softDeleteSQL(database, schema, table, column, where string) string {
+	SQL := config.PrestConf.Adapter.UpdateSQL(database, schema, table, fmt.Sprintf(`"%s" = now()`, column))
+	return fmt.Sprint(SQL, " WHERE ", andWhere(where, fmt.Sprintf(`"%s" IS NULL`, column)))
+}
+
+// andWhere adds condition to the where clause of a request
+func andWhere(where, condition string) string {
+	if where == "" {
+		return condition
+	}
+	return fmt.Sprintf("(%s) AND %s", where, condition)
+}
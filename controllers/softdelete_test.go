@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/testutils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setSoftDelete(t *testing.T, enabled bool, column string, tables []string) {
+	t.Helper()
+	enabledBefore, columnBefore, tablesBefore := config.PrestConf.SoftDeleteEnabled, config.PrestConf.SoftDeleteColumn, config.PrestConf.SoftDeleteTables
+	t.Cleanup(func() {
+		config.PrestConf.SoftDeleteEnabled, config.PrestConf.SoftDeleteColumn, config.PrestConf.SoftDeleteTables = enabledBefore, columnBefore, tablesBefore
+	})
+	config.PrestConf.SoftDeleteEnabled, config.PrestConf.SoftDeleteColumn, config.PrestConf.SoftDeleteTables = enabled, column, tables
+}
+
+func TestNotDeleted(t *testing.T) {
+	var testCases = []struct {
+		description string
+		enabled     bool
+		column      string
+		tables      []string
+		query       string
+		expected    string
+		err         string
+	}{
+		{"disabled", false, "deleted_at", nil, "", "", ""},
+		{"no table listed", true, "deleted_at", nil, "", "", ""},
+		{"listed table", true, "removed_on", []string{"public.orders"}, "", `"orders"."removed_on" IS NULL`, ""},
+		{"table not listed", true, "deleted_at", []string{"public.users"}, "", "", ""},
+		{"table listed in another schema", true, "deleted_at", []string{"archive.orders"}, "", "", ""},
+		{"unqualified table", true, "deleted_at", []string{"orders"}, "", "", ""},
+		{"with deleted rows", true, "deleted_at", []string{"public.orders"}, "_with_deleted=true", "", ""},
+		{"invalid column", true, `deleted_at" = now(); --`, []string{"public.orders"}, "", "", "invalid soft-delete column"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			setSoftDelete(t, tc.enabled, tc.column, tc.tables)
+			queries, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			condition, err := notDeleted("public", "orders", queries)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, condition)
+		})
+	}
+}
+
+func TestSoftDeleteSQL(t *testing.T) {
+	require.Equal(t,
+		`UPDATE "db"."public"."orders" SET "deleted_at" = now() WHERE ("id" = $1) AND "deleted_at" IS NULL`,
+		softDeleteSQL("db", "public", "orders", "deleted_at", `"id" = $1`))
+	require.Equal(t,
+		`UPDATE "db"."public"."orders" SET "deleted_at" = now() WHERE "deleted_at" IS NULL`,
+		softDeleteSQL("db", "public", "orders", "deleted_at", ""))
+}
+
+func TestSoftDelete(t *testing.T) {
+	setSoftDelete(t, true, "deleted_at", []string{"public.test_soft_delete"})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{database}/{schema}/{table}", setHTTPTimeoutMiddleware(SelectFromTables)).Methods("GET")
+	router.HandleFunc("/{database}/{schema}/{table}", setHTTPTimeoutMiddleware(DeleteFromTable)).Methods("DELETE")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tableURL := server.URL + "/prest-test/public/test_soft_delete"
+	testutils.DoRequest(t, tableURL+"?name=removed", nil, "DELETE", http.StatusOK, "DeleteFromTable", `"rows_affected":1`)
+	testutils.DoRequest(t, tableURL+"?name=removed", nil, "DELETE", http.StatusOK, "DeleteFromTable", `"rows_affected":0`)
+
+	testutils.DoRequest(t, tableURL+"?name=removed", nil, "GET", http.StatusOK, "SelectFromTables", "[]")
+	testutils.DoRequest(t, tableURL+"?name=kept", nil, "GET", http.StatusOK, "SelectFromTables", `"name": "kept"`)
+	testutils.DoRequest(t, tableURL+"?name=removed&_with_deleted=true", nil, "GET", http.StatusOK, "SelectFromTables", `"name": "removed"`)
+}
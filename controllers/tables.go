@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/postgres"
 	"github.com/prest/prest/v2/config"
 	pctx "github.com/prest/prest/v2/context"
 	"github.com/prest/prest/v2/controllers/auth"
@@ -78,7 +83,7 @@ func GetTablesByDatabaseAndSchema(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
@@ -124,7 +129,7 @@ func GetTablesByDatabaseAndSchema(w http.ResponseWriter, r *http.Request) {
 	// send ctx to query the proper DB
 	sc := config.PrestConf.Adapter.QueryCtx(ctx, sqlSchemaTables, valuesAux...)
 	if sc.Err() != nil {
-		jsonError(w, sc.Err().Error(), http.StatusBadRequest)
+		queryError(w, r, sc.Err().Error(), http.StatusBadRequest)
 		return
 	}
 	w.Write(sc.Bytes())
@@ -145,7 +150,7 @@ func SelectFromTables(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate path identifiers early using safe segments policy
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
@@ -227,19 +232,52 @@ func SelectFromTables(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	sqlSelect := query
-	if requestWhere != "" {
-		sqlSelect = fmt.Sprint(
-			query,
-			" WHERE ",
-			requestWhere)
+	// _with_deleted: query string, lists the soft-deleted rows too
+	deletedCondition, err := notDeleted(schema, table, queries)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deletedCondition != "" {
+		requestWhere = andWhere(requestWhere, deletedCondition)
+	}
+	// _paginate: query string, offset (_page) or keyset (_keyset, _cursor)
+	mode, err := paginationMode(queries)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var keyset keysetPage
+	if mode == "keyset" {
+		keyset, err = keysetPagination(queries, len(values)+1)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// the keyset cursor selects the page, X-Total-Count counts every row
+	totalWhere, totalValues := requestWhere, values
+	if keyset.where != "" {
+		requestWhere = andWhere(requestWhere, keyset.where)
+		values = append(values[:len(values):len(values)], keyset.values...)
 	}
 
 	// sql query formatting if there is a groupby rule
 	groupBySQL := config.PrestConf.Adapter.GroupByClause(r)
-	if groupBySQL != "" {
-		sqlSelect = fmt.Sprintf("%s %s", sqlSelect, groupBySQL)
+	selectWhere := func(where string) string {
+		sql := query
+		if where != "" {
+			sql = fmt.Sprint(sql, " WHERE ", where)
+		}
+		if groupBySQL != "" {
+			sql = fmt.Sprintf("%s %s", sql, groupBySQL)
+		}
+		return sql
 	}
+	sqlSelect := selectWhere(requestWhere)
+
+	// rows matched before the cursor, ordering and pagination, for X-Total-Count
+	sqlTotal := selectWhere(totalWhere)
 
 	// sql query formatting if there is a orderby rule
 	order, err := config.PrestConf.Adapter.OrderByRequest(r)
@@ -248,18 +286,39 @@ func SelectFromTables(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if order != "" && mode == "keyset" {
+		jsonError(w, "_order can't be combined with keyset pagination, sort with _keyset", http.StatusBadRequest)
+		return
+	}
 	if order != "" {
 		sqlSelect = fmt.Sprintf("%s %s", sqlSelect, order)
 	}
 
 	// sql query formatting if there is a paganate rule
-	page, err := config.PrestConf.Adapter.PaginateIfPossible(r)
+	selectValues := values
+	var page string
+	if mode == "keyset" {
+		page = fmt.Sprintf("%s LIMIT $%d", keyset.order, len(values)+1)
+		selectValues = append(values[:len(values):len(values)], keyset.size)
+	} else {
+		page, err = config.PrestConf.Adapter.PaginateIfPossible(r)
+		if err != nil {
+			err = fmt.Errorf("could not perform PaginateIfPossible: %v", err)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	sqlSelect = fmt.Sprint(sqlSelect, " ", page)
+
+	// _limit: query string, bounded by the guardrails
+	limit, err := rowLimit(queries, page)
 	if err != nil {
-		err = fmt.Errorf("could not perform PaginateIfPossible: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	sqlSelect = fmt.Sprint(sqlSelect, " ", page)
+	if limit != "" {
+		sqlSelect = fmt.Sprint(sqlSelect, " ", limit)
+	}
 
 	ctx := context.WithValue(r.Context(), pctx.DBNameKey, database)
 
@@ -267,22 +326,50 @@ func SelectFromTables(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(timeout))
 	defer cancel()
 
+	if err = checkCost(ctx, sqlSelect, selectValues); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	runQuery := config.PrestConf.Adapter.QueryCtx
 	// QueryCount returns the first record of the postgresql return as a non-list object
 	if countFirst {
 		runQuery = config.PrestConf.Adapter.QueryCountCtx
 	}
-	sc := runQuery(ctx, sqlSelect, values...)
+	sc := runQuery(ctx, sqlSelect, selectValues...)
 	if err = sc.Err(); err != nil {
 		log.Errorln(err)
 		if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
 			jsonError(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		queryError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// _total: query string, runs a second COUNT query for the pagination headers
+	if queries.Get(totalCountKey) == "true" && countQuery == "" {
+		total := config.PrestConf.Adapter.QueryCountCtx(ctx, totalCountSQL(sqlTotal), totalValues...)
+		if err = total.Err(); err != nil {
+			queryError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var count struct {
+			Count int64 `json:"count"`
+		}
+		if err = json.Unmarshal(total.Bytes(), &count); err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		setPaginationHeaders(w, r.URL, count.Count)
+	}
+
+	if mode == "keyset" {
+		if cursor := nextCursor(sc.Bytes(), keyset.column, keyset.size); cursor != "" {
+			w.Header().Set(nextCursorHeader, cursor)
+		}
+	}
+
 	if r.Method == "GET" {
 		// Cache arrow if enabled
 		config.PrestConf.Cache.BuntSet(r.URL.String(), string(sc.Bytes()))
@@ -305,11 +392,16 @@ func InsertInTables(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
 
+	if isJSONArrayBody(r) {
+		insertRows(w, r, database, schema, table)
+		return
+	}
+
 	names, placeholders, values, err := config.PrestConf.Adapter.ParseInsertRequest(r)
 	if err != nil {
 		err = fmt.Errorf("could not perform InsertInTables: %v", err)
@@ -317,7 +409,14 @@ func InsertInTables(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sql := config.PrestConf.Adapter.InsertSQL(database, schema, table, names, placeholders)
+	conflictSyntax, doNothing, err := config.PrestConf.Adapter.OnConflictByRequest(r, names)
+	if err != nil {
+		err = fmt.Errorf("could not perform OnConflictByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sql := config.PrestConf.Adapter.InsertSQL(database, schema, table, names, placeholders) + conflictSyntax
 
 	// set db name on ctx
 	ctx := context.WithValue(r.Context(), pctx.DBNameKey, database)
@@ -327,20 +426,117 @@ func InsertInTables(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	sc := config.PrestConf.Adapter.InsertCtx(ctx, sql, values...)
-	if err = sc.Err(); err != nil {
+	if err = sc.Err(); skippedConflict(err, doNothing) {
+		// the row conflicted and nothing was done
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("{}"))
+		return
+	}
+	if err != nil {
 		if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
 			err = fmt.Errorf("relation does not exist: %v", err)
 			jsonError(w, err.Error(), http.StatusNotFound)
 			return
 		}
 		err = fmt.Errorf("could not perform InsertInTables: %v", err)
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		queryError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 	w.Write(sc.Bytes())
 }
 
+// insertRows inserts the JSON array body of an insert request with a single
+// multi-row INSERT, answering the rows_affected or, with _returning, the rows
+func insertRows(w http.ResponseWriter, r *http.Request, database, schema, table string) {
+	names, placeholders, values, err := config.PrestConf.Adapter.ParseBatchInsertRequest(r)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("could not perform InsertInTables: %v", err), batchErrorStatus(err))
+		return
+	}
+
+	conflictSyntax, _, err := config.PrestConf.Adapter.OnConflictByRequest(r, names)
+	if err != nil {
+		err = fmt.Errorf("could not perform OnConflictByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sql := config.PrestConf.Adapter.InsertSQL(database, schema, table, names, placeholders) + conflictSyntax
+
+	// set db name on ctx
+	ctx := context.WithValue(r.Context(), pctx.DBNameKey, database)
+
+	timeout, _ := ctx.Value(pctx.HTTPTimeoutKey).(int)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(timeout))
+	defer cancel()
+
+	sc := config.PrestConf.Adapter.BatchInsertValuesCtx(ctx, sql, values...)
+	if err = sc.Err(); err != nil {
+		if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
+			err = fmt.Errorf("relation does not exist: %v", err)
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		err = fmt.Errorf("could not perform InsertInTables: %v", err)
+		queryError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Has("_returning") {
+		w.WriteHeader(http.StatusCreated)
+		w.Write(sc.Bytes())
+		return
+	}
+	var rows []json.RawMessage
+	if err = json.Unmarshal(sc.Bytes(), &rows); err != nil {
+		err = fmt.Errorf("could not perform InsertInTables: %v", err)
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"rows_affected": len(rows)})
+}
+
+// skippedConflict tells whether an insert returned no row because ON
+// CONFLICT DO NOTHING skipped it, other inserts returning nothing failed
+func skippedConflict(err error, doNothing bool) bool {
+	return doNothing && errors.Is(err, sql.ErrNoRows)
+}
+
+// batchErrorStatus maps a batch parse error to its HTTP status
+func batchErrorStatus(err error) int {
+	if errors.Is(err, postgres.ErrBatchTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// isJSONArrayBody tells whether the request body is a JSON array, peeking
+// past the leading white space so the body is still read as it streams in
+func isJSONArrayBody(r *http.Request) bool {
+	if r.Body == nil {
+		return false
+	}
+	br := bufio.NewReader(r.Body)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			// JSON ignores leading white space, dropping it keeps the peek small
+			br.Discard(1)
+		default:
+			return b[0] == '['
+		}
+	}
+}
+
 // BatchInsertInTables perform insert in specific table from a batch request
 func BatchInsertInTables(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -355,15 +551,14 @@ func BatchInsertInTables(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
 
 	names, placeholders, values, err := config.PrestConf.Adapter.ParseBatchInsertRequest(r)
 	if err != nil {
-		err = fmt.Errorf("could not perform BatchInsertInTables: %v", err)
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, fmt.Sprintf("could not perform BatchInsertInTables: %v", err), batchErrorStatus(err))
 		return
 	}
 
@@ -389,7 +584,7 @@ func BatchInsertInTables(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		err = fmt.Errorf("could not perform BatchInsertInTables: %v", err)
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		queryError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -410,13 +605,13 @@ func DeleteFromTable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		err := fmt.Errorf("invalid identifier in path")
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -429,8 +624,17 @@ func DeleteFromTable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	softDelete, err := softDeleteColumn(schema, table)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	sql := config.PrestConf.Adapter.DeleteSQL(database, schema, table)
-	if where != "" {
+	runDelete := config.PrestConf.Adapter.DeleteCtx
+	if softDelete != "" {
+		sql = softDeleteSQL(database, schema, table, softDelete, where)
+		runDelete = config.PrestConf.Adapter.UpdateCtx
+	} else if where != "" {
 		sql = fmt.Sprint(sql, " WHERE ", where)
 	}
 
@@ -454,7 +658,7 @@ func DeleteFromTable(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(timeout))
 	defer cancel()
 
-	sc := config.PrestConf.Adapter.DeleteCtx(ctx, sql, values...)
+	sc := runDelete(ctx, sql, values...)
 	if err = sc.Err(); err != nil {
 		if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
 			err = fmt.Errorf("relation does not exist: %v", err)
@@ -462,7 +666,7 @@ func DeleteFromTable(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		err = fmt.Errorf("could not perform DeleteFromTable: %v", err)
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		queryError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 	w.Write(sc.Bytes())
@@ -482,7 +686,7 @@ func UpdateTable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
@@ -537,7 +741,7 @@ func UpdateTable(w http.ResponseWriter, r *http.Request) {
 			jsonError(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		queryError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 	w.Write(sc.Bytes())
@@ -557,7 +761,7 @@ func ShowTable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate safe segments for path params
-	if !ident.IsSafeSegment(database) || !ident.IsSafeSegment(schema) || !ident.IsSafeSegment(table) {
+	if !ident.IsSegment(database) || !ident.IsSegment(schema) || !ident.IsSegment(table) {
 		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
 		return
 	}
@@ -572,7 +776,7 @@ func ShowTable(w http.ResponseWriter, r *http.Request) {
 	sc := config.PrestConf.Adapter.ShowTableCtx(ctx, schema, table)
 	if sc.Err() != nil {
 		errorMessage := fmt.Sprintf("error to execute query, schema error %s", sc.Err())
-		jsonError(w, errorMessage, http.StatusBadRequest)
+		queryError(w, r, errorMessage, http.StatusBadRequest)
 		return
 	}
 	w.Write(sc.Bytes())
@@ -3,14 +3,18 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/prest/prest/v2/adapters/postgres"
 	"github.com/prest/prest/v2/config"
@@ -214,6 +218,7 @@ func TestInsertInTables(t *testing.T) {
 		{"execute insert in a table with invalid schema", "/prest-test/0public/test", m, http.StatusNotFound},
 		{"execute insert in a table with invalid table", "/prest-test/public/0test", m, http.StatusNotFound},
 		{"execute insert in a table with invalid body", "/prest-test/public/test", nil, http.StatusBadRequest},
+		{"execute insert skipped by a trigger", "/prest-test/public/test_skip_insert", m, http.StatusBadRequest},
 
 		{"execute insert in a database that does not exist", "/invalid/public/0test", m, http.StatusBadRequest},
 	}
@@ -224,6 +229,108 @@ func TestInsertInTables(t *testing.T) {
 	}
 }
 
+func TestIsJSONArrayBody(t *testing.T) {
+	for body, want := range map[string]bool{
+		`[{"name": "a"}]`:                true,
+		" \r\n\t[]":                      true,
+		`{"name": "a"}`:                  false,
+		"   ":                            false,
+		strings.Repeat(" ", 8192) + "[]": true,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if got := isJSONArrayBody(r); got != want {
+			t.Errorf("isJSONArrayBody(%.20q) = %v, want %v", body, got, want)
+		}
+	}
+
+	// only the head of the body is read, the rest is left to the handler
+	r := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader(" ["), iotest.ErrReader(io.ErrUnexpectedEOF)))
+	if !isJSONArrayBody(r) {
+		t.Error("expected a JSON array")
+	}
+	body, err := io.ReadAll(r.Body)
+	if string(body) != "[" || err != io.ErrUnexpectedEOF {
+		t.Errorf("body = %q, %v", body, err)
+	}
+}
+
+func TestSkippedConflict(t *testing.T) {
+	if !skippedConflict(sql.ErrNoRows, true) {
+		t.Error("expected a row skipped by ON CONFLICT DO NOTHING")
+	}
+	if skippedConflict(sql.ErrNoRows, false) {
+		t.Error("a plain insert returning no row must fail")
+	}
+	if skippedConflict(nil, true) || skippedConflict(errors.New("pq: error"), true) {
+		t.Error("only a missing row is a skipped conflict")
+	}
+}
+
+func TestInsertInTablesOnConflict(t *testing.T) {
+	m := map[string]interface{}{"name": "prest-upsert"}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{database}/{schema}/{table}", setHTTPTimeoutMiddleware(InsertInTables)).
+		Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	var testCases = []struct {
+		description string
+		url         string
+		request     interface{}
+		status      int
+		body        []string
+	}{
+		{"insert a new row", "/prest-test/public/test4?_on_conflict=name", m, http.StatusCreated, []string{`"name":"prest-upsert"`}},
+		{"skip a conflicting row", "/prest-test/public/test4?_on_conflict=name&_do=nothing", m, http.StatusOK, []string{`{}`}},
+		{"update a conflicting row", "/prest-test/public/test4?_on_conflict=name&_do=update", m, http.StatusCreated, []string{`"name":"prest-upsert"`}},
+		{"skip conflicting rows of an array", "/prest-test/public/test4?_on_conflict=name", []interface{}{m}, http.StatusCreated, []string{`{"rows_affected":0}`}},
+		{"invalid conflict column", "/prest-test/public/test4?_on_conflict=name)", m, http.StatusBadRequest, nil},
+		{"invalid conflict action", "/prest-test/public/test4?_on_conflict=name&_do=delete", m, http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Log(tc.description)
+		testutils.DoRequest(t, server.URL+tc.url, tc.request, "POST", tc.status, "InsertInTables", tc.body...)
+	}
+}
+
+func TestInsertRowsInTables(t *testing.T) {
+	rows := []map[string]interface{}{{"name": "rows-a"}, {"name": "rows-b"}}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{database}/{schema}/{table}", setHTTPTimeoutMiddleware(InsertInTables)).
+		Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	var testCases = []struct {
+		description string
+		url         string
+		request     interface{}
+		status      int
+		body        []string
+	}{
+		{"insert an array of rows", "/prest-test/public/test", rows, http.StatusCreated, []string{`{"rows_affected":2}`}},
+		{"insert an array of rows returning them", "/prest-test/public/test?_returning=*", rows, http.StatusCreated, []string{`"name":"rows-a"`, `"name":"rows-b"`}},
+		{"insert an array of rows with an invalid column", "/prest-test/public/test", []map[string]interface{}{{"name;": "x"}}, http.StatusBadRequest, nil},
+		{"insert an empty array of rows", "/prest-test/public/test", []map[string]interface{}{}, http.StatusBadRequest, nil},
+		{"insert an array of rows in a table that does not exist", "/prest-test/public/0test", rows, http.StatusNotFound, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Log(tc.description)
+		testutils.DoRequest(t, server.URL+tc.url, tc.request, "POST", tc.status, "InsertInTables", tc.body...)
+	}
+
+	t.Run("too many rows", func(t *testing.T) {
+		defer func(max int) { config.PrestConf.BatchMaxSize = max }(config.PrestConf.BatchMaxSize)
+		config.PrestConf.BatchMaxSize = 1
+		testutils.DoRequest(t, server.URL+"/prest-test/public/test", rows, "POST", http.StatusRequestEntityTooLarge, "InsertInTables")
+	})
+}
+
 func TestBatchInsertInTables(t *testing.T) {
 	m := make([]map[string]interface{}, 0)
 	m = append(m, map[string]interface{}{"name": "bprest"}, map[string]interface{}{"name": "aprest"})
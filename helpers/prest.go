@@ -1,13 +1,49 @@
 package helpers
 
+import "runtime"
+
+// unknown is reported for build metadata not set through -ldflags
+const unknown = "unknown"
+
 var (
 	// PrestVersionNumber repesemts prest version.
 	PrestVersionNumber = "1.4.0"
 	// CommitHash for version
 	CommitHash string
+	// BuildDate of the binary, set with -ldflags
+	BuildDate string
+	// GoVersion used to build the binary, defaults to the running runtime
+	GoVersion string
 )
 
+// BuildInfo identifies the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
 // PrestReleaseVersion is same as pREST Version.
 func PrestReleaseVersion() string {
 	return PrestVersionNumber
 }
+
+// Build returns the metadata embedded at build time, e.g.
+//
+//	go build -ldflags "-X github.com/prest/prest/v2/helpers.CommitHash=$(git rev-parse HEAD)"
+func Build() BuildInfo {
+	return BuildInfo{
+		Version:   orDefault(PrestVersionNumber, "dev"),
+		GitCommit: orDefault(CommitHash, unknown),
+		BuildDate: orDefault(BuildDate, unknown),
+		GoVersion: orDefault(GoVersion, runtime.Version()),
+	}
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
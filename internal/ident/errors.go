@@ -0,0 +1,34 @@
+package ident
+
+import "fmt"
+
+// Reason describes why an identifier was rejected.
+type Reason string
+
+// Reasons reported by InvalidIdentifierError.
+const (
+	ReasonEmptySegment      Reason = "empty segment"
+	ReasonTooLong           Reason = "segment too long"
+	ReasonBadCharacter      Reason = "bad character"
+	ReasonStartsWithDigit   Reason = "starts with digit"
+	ReasonReserved          Reason = "reserved keyword"
+	ReasonUnterminatedQuote Reason = "unterminated quote"
+)
+
+// InvalidIdentifierError is returned when an identifier fails validation.
+// Callers can retrieve it with errors.As to report exactly which part of
+// the input was wrong.
+type InvalidIdentifierError struct {
+	// Input is the full identifier as given by the caller.
+	Input string
+	// Segment is the dot-separated part of Input that failed validation.
+	Segment string
+	Reason  Reason
+}
+
+func (e *InvalidIdentifierError) Error() string {
+	if e.Segment == e.Input {
+		return fmt.Sprintf("invalid identifier: %s: %s", e.Input, e.Reason)
+	}
+	return fmt.Sprintf("invalid identifier: %s: %s %q", e.Input, e.Reason, e.Segment)
+}
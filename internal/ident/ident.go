@@ -2,32 +2,92 @@ package ident
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
+	"sync/atomic"
 	"unicode"
 )
 
-var re = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+// DefaultMaxSegmentLength is the PostgreSQL identifier limit (NAMEDATALEN - 1)
+// for a stock build.
+const DefaultMaxSegmentLength = 63
+
+var maxSegmentLength atomic.Int64
+
+func init() {
+	maxSegmentLength.Store(DefaultMaxSegmentLength)
+}
+
+// SetMaxSegmentLength changes the maximum length, in bytes, of a single
+// identifier segment. Use it when PostgreSQL is compiled with a larger
+// NAMEDATALEN. The default is DefaultMaxSegmentLength.
+func SetMaxSegmentLength(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid max segment length: %d", n)
+	}
+	maxSegmentLength.Store(int64(n))
+	return nil
+}
+
+// MaxSegmentLength returns the configured maximum segment length in bytes.
+func MaxSegmentLength() int {
+	return int(maxSegmentLength.Load())
+}
+
+// tooLong reports whether seg exceeds the configured limit. Like PostgreSQL,
+// the length is measured in bytes rather than runes.
+func tooLong(seg string) bool {
+	return len(seg) > MaxSegmentLength()
+}
 
 // IsValid reports whether s is a valid SQL identifier or dotted identifier path.
+// Each segment must start with a letter or underscore followed by letters,
+// digits or underscores. Like PostgreSQL, letters are not limited to ASCII.
 func IsValid(s string) bool {
-	if !re.MatchString(s) {
-		return false
-	}
+	return validate(s) == nil
+}
+
+// validate returns an *InvalidIdentifierError describing the first invalid
+// segment of the dotted path s, or nil when s is valid.
+func validate(s string) error {
 	for _, part := range strings.Split(s, ".") {
-		if len(part) == 0 || len(part) > 63 {
-			return false
+		if reason := checkSegment(part); reason != "" {
+			return &InvalidIdentifierError{Input: s, Segment: part, Reason: reason}
 		}
 	}
-	return true
+	return nil
 }
 
-// IsSafeSegment reports whether s is a safe, single identifier segment for path params
-// like database, schema, or table. It allows letters, digits, underscore and hyphen,
-// PostgreSQL identifiers don't support. These must be quoted when used in SQL.
-// with length up to 63, and disallows dots and quotes.
-func IsSafeSegment(s string) bool {
-	if s == "" || len(s) > 63 {
+// checkSegment returns why s is not a valid identifier segment, or an empty
+// Reason when it is.
+func checkSegment(s string) Reason {
+	if len(s) == 0 {
+		return ReasonEmptySegment
+	}
+	if tooLong(s) {
+		return ReasonTooLong
+	}
+	for i, r := range s {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		if i == 0 && unicode.IsDigit(r) {
+			return ReasonStartsWithDigit
+		}
+		return ReasonBadCharacter
+	}
+	return ""
+}
+
+// IsSegment reports whether s is a safe, single identifier segment for path
+// params like database, schema, or table.
+//
+// A segment differs from the dotted identifiers accepted by IsValid: it is
+// made of letters, digits, underscores and hyphens in any order, has a length
+// of 1 up to MaxSegmentLength bytes, and never contains dots or quotes.
+// Hyphens and leading digits are not valid in unquoted PostgreSQL identifiers,
+// so a segment must always be quoted when used in SQL.
+func IsSegment(s string) bool {
+	if s == "" || tooLong(s) {
 		return false
 	}
 	for _, r := range s {
@@ -38,18 +98,221 @@ func IsSafeSegment(s string) bool {
 	return true
 }
 
+// IsSafeSegment reports whether s is a safe, single identifier segment.
+//
+// Deprecated: use IsSegment.
+func IsSafeSegment(s string) bool {
+	return IsSegment(s)
+}
+
+// Option changes the behavior of Quote.
+type Option func(*options)
+
+type options struct {
+	rejectReserved bool
+}
+
+// RejectReserved makes Quote fail when any segment of the path is a reserved
+// keyword, for contexts where a name is expected to work without quoting.
+func RejectReserved() Option {
+	return func(o *options) {
+		o.rejectReserved = true
+	}
+}
+
+// Dialect selects the quoting style used for identifiers.
+type Dialect int
+
+const (
+	// Postgres quotes identifiers with double quotes. It is the default.
+	Postgres Dialect = iota
+	// MySQL quotes identifiers with backticks.
+	MySQL
+)
+
+func (d Dialect) quoteChar() string {
+	if d == MySQL {
+		return "`"
+	}
+	return `"`
+}
+
 // Quote validates and returns a safely quoted identifier path like "a"."b".
-func Quote(s string) (string, error) {
-	if !IsValid(s) {
-		return "", fmt.Errorf("invalid identifier: %s", s)
+func Quote(s string, opts ...Option) (string, error) {
+	return QuoteDialect(s, Postgres, opts...)
+}
+
+// QuoteDialect validates s and quotes it for dialect d, e.g. `a`.`b` for MySQL.
+// Validation rules are the same for every dialect.
+func QuoteDialect(s string, d Dialect, opts ...Option) (string, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
 	}
+	if err := validate(s); err != nil {
+		return "", err
+	}
+	if o.rejectReserved {
+		for _, part := range strings.Split(s, ".") {
+			if IsReservedKeyword(part) {
+				return "", &InvalidIdentifierError{Input: s, Segment: part, Reason: ReasonReserved}
+			}
+		}
+	}
+	q := d.quoteChar()
 	parts := strings.Split(s, ".")
 	for i := range parts {
-		parts[i] = `"` + strings.ReplaceAll(parts[i], `"`, `""`) + `"`
+		parts[i] = q + strings.ReplaceAll(parts[i], q, q+q) + q
 	}
 	return strings.Join(parts, "."), nil
 }
 
+// QuoteWithStar is like Quote but accepts a trailing * segment, which is kept
+// unquoted: public.users.* becomes "public"."users".* and a bare * is returned
+// as is. A star in any other position is rejected.
+func QuoteWithStar(s string) (string, error) {
+	if s == "*" {
+		return s, nil
+	}
+	if prefix, ok := strings.CutSuffix(s, ".*"); ok {
+		q, err := Quote(prefix)
+		if err != nil {
+			return "", err
+		}
+		return q + ".*", nil
+	}
+	return Quote(s)
+}
+
+// QuoteWithAlias validates and quotes a column path and its alias, returning
+// a select expression like "schema"."col" AS "alias". The alias must be a
+// single segment.
+func QuoteWithAlias(col, alias string) (string, error) {
+	q, err := Quote(col)
+	if err != nil {
+		return "", err
+	}
+	if reason := checkSegment(alias); reason != "" {
+		return "", &InvalidIdentifierError{Input: alias, Segment: alias, Reason: reason}
+	}
+	return q + " AS " + `"` + alias + `"`, nil
+}
+
+// QuoteAll quotes every name in names. It returns the first validation error,
+// reporting the index of the offending entry.
+func QuoteAll(names []string) ([]string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		q, err := Quote(name)
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		quoted[i] = q
+	}
+	return quoted, nil
+}
+
+// QuoteJoin quotes every name in names and joins them with sep, ready to be
+// embedded in a column list like "a","b".
+func QuoteJoin(names []string, sep string) (string, error) {
+	quoted, err := QuoteAll(names)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(quoted, sep), nil
+}
+
+// Unquote reverses Quote: it splits a quoted identifier path like "a"."b" on
+// unquoted dots, strips the surrounding double quotes and collapses doubled
+// quotes back to a single one. Unquoted segments are accepted when they are
+// valid identifiers.
+func Unquote(s string) ([]string, error) {
+	parts, _, err := splitQuoted(s)
+	return parts, err
+}
+
+// IsQuoted reports whether s is a well-formed dotted path in which every
+// segment is wrapped in double quotes, like "public"."users".
+func IsQuoted(s string) bool {
+	_, allQuoted, err := splitQuoted(s)
+	return err == nil && allQuoted
+}
+
+// NormalizeQuoted returns a quoted identifier path that is safe to embed in
+// SQL. Input that is already quoted, like "public"."users", is validated and
+// returned unchanged instead of being quoted twice; anything else goes
+// through Quote.
+func NormalizeQuoted(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return Quote(s)
+	}
+	_, allQuoted, err := splitQuoted(s)
+	if err != nil {
+		return "", err
+	}
+	if !allQuoted {
+		return "", &InvalidIdentifierError{Input: s, Segment: s, Reason: ReasonBadCharacter}
+	}
+	return s, nil
+}
+
+// splitQuoted parses a dotted path whose segments are either double-quoted or
+// plain identifiers, and reports whether all of them were quoted.
+func splitQuoted(s string) (parts []string, allQuoted bool, err error) {
+	allQuoted = true
+	i := 0
+	for {
+		var seg string
+		if i < len(s) && s[i] == '"' {
+			var b strings.Builder
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] != '"' {
+					b.WriteByte(s[i])
+					continue
+				}
+				if i+1 < len(s) && s[i+1] == '"' {
+					b.WriteByte('"')
+					i++
+					continue
+				}
+				i++
+				closed = true
+				break
+			}
+			seg = b.String()
+			if !closed {
+				return nil, false, &InvalidIdentifierError{Input: s, Segment: seg, Reason: ReasonUnterminatedQuote}
+			}
+			if len(seg) == 0 {
+				return nil, false, &InvalidIdentifierError{Input: s, Segment: seg, Reason: ReasonEmptySegment}
+			}
+			if tooLong(seg) {
+				return nil, false, &InvalidIdentifierError{Input: s, Segment: seg, Reason: ReasonTooLong}
+			}
+		} else {
+			end := strings.IndexByte(s[i:], '.')
+			if end < 0 {
+				end = len(s) - i
+			}
+			seg = s[i : i+end]
+			if reason := checkSegment(seg); reason != "" {
+				return nil, false, &InvalidIdentifierError{Input: s, Segment: seg, Reason: reason}
+			}
+			allQuoted = false
+			i += end
+		}
+		parts = append(parts, seg)
+		if i == len(s) {
+			return parts, allQuoted, nil
+		}
+		if s[i] != '.' {
+			return nil, false, &InvalidIdentifierError{Input: s, Segment: seg, Reason: ReasonBadCharacter}
+		}
+		i++
+	}
+}
+
 // SplitAndValidateCSV splits a comma-separated list and validates each identifier.
 func SplitAndValidateCSV(s string) ([]string, error) {
 	if s == "" {
@@ -57,8 +320,25 @@ func SplitAndValidateCSV(s string) ([]string, error) {
 	}
 	parts := strings.Split(s, ",")
 	for _, p := range parts {
-		if !IsValid(p) {
-			return nil, fmt.Errorf("invalid identifier: %s", p)
+		if err := validate(p); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// SplitAndValidateCSVTrim is like SplitAndValidateCSV but trims surrounding
+// whitespace from each identifier before validating it, so "id, name" is
+// accepted. An all-whitespace entry is still an invalid, empty identifier.
+func SplitAndValidateCSVTrim(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+		if err := validate(parts[i]); err != nil {
+			return nil, err
 		}
 	}
 	return parts, nil
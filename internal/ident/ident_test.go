@@ -1,6 +1,8 @@
 package ident
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -91,6 +93,17 @@ func TestQuote(t *testing.T) {
 		// Valid: mixed case
 		{"FooBar", `"FooBar"`, false},
 		{"fooBar.BazQux", `"fooBar"."BazQux"`, false},
+		// Valid: unicode letters
+		{"café", `"café"`, false},
+		{"café.naïve", `"café"."naïve"`, false},
+		{"Ärger_1", `"Ärger_1"`, false},
+		{"_ñ", `"_ñ"`, false},
+		// Invalid: unicode symbols and unicode digits first
+		{"table😀", "", true},
+		{"😀", "", true},
+		{"café.naïve😀", "", true},
+		{"١abc", "", true},
+		{"café naïve", "", true},
 	}
 
 	for _, tt := range tests {
@@ -105,7 +118,52 @@ func TestQuote(t *testing.T) {
 	}
 }
 
-func TestIsSafeSegment(t *testing.T) {
+func TestSetMaxSegmentLength(t *testing.T) {
+	t.Cleanup(func() { _ = SetMaxSegmentLength(DefaultMaxSegmentLength) })
+
+	long := "a" + strings.Repeat("b", 99) // 100 chars
+	if IsValid(long) {
+		t.Fatalf("IsValid(%d chars) = true with default limit", len(long))
+	}
+	if _, err := Quote("public." + long); err == nil {
+		t.Fatalf("Quote(%d chars) succeeded with default limit", len(long))
+	}
+
+	if err := SetMaxSegmentLength(128); err != nil {
+		t.Fatalf("SetMaxSegmentLength(128) error = %v", err)
+	}
+	if !IsValid(long) {
+		t.Errorf("IsValid(%d chars) = false after raising limit", len(long))
+	}
+	if got, err := Quote("public." + long); err != nil || got != `"public"."`+long+`"` {
+		t.Errorf("Quote(%d chars) = %q, %v after raising limit", len(long), got, err)
+	}
+	if !IsSegment(long) {
+		t.Errorf("IsSegment(%d chars) = false after raising limit", len(long))
+	}
+
+	for _, n := range []int{0, -1} {
+		if err := SetMaxSegmentLength(n); err == nil {
+			t.Errorf("SetMaxSegmentLength(%d) expected error", n)
+		}
+	}
+	if MaxSegmentLength() != 128 {
+		t.Errorf("MaxSegmentLength() = %d, rejected values must not change it", MaxSegmentLength())
+	}
+}
+
+func TestSegmentLengthCountsBytes(t *testing.T) {
+	// 32 two-byte runes: 32 runes but 64 bytes
+	s := strings.Repeat("é", 32)
+	if IsSegment(s) {
+		t.Errorf("IsSegment(%d bytes) = true, want false", len(s))
+	}
+	if !IsSegment(s[:62]) {
+		t.Errorf("IsSegment(%d bytes) = false, want true", len(s[:62]))
+	}
+}
+
+func TestIsSegment(t *testing.T) {
 	tests := []struct {
 		input string
 		want  bool
@@ -133,13 +191,336 @@ func TestIsSafeSegment(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := IsSafeSegment(tt.input)
-		if got != tt.want {
+		if got := IsSegment(tt.input); got != tt.want {
+			t.Errorf("IsSegment(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		if got := IsSafeSegment(tt.input); got != tt.want {
 			t.Errorf("IsSafeSegment(%q) = %v, want %v", tt.input, got, tt.want)
 		}
 	}
 }
 
+func TestQuoteDialect(t *testing.T) {
+	tests := []struct {
+		input   string
+		dialect Dialect
+		want    string
+		wantErr bool
+	}{
+		{"table", Postgres, `"table"`, false},
+		{"schema.table", Postgres, `"schema"."table"`, false},
+		{"table", MySQL, "`table`", false},
+		{"a.b", MySQL, "`a`.`b`", false},
+		{"café.naïve", MySQL, "`café`.`naïve`", false},
+		{"a`b", MySQL, "", true},
+		{"foo..bar", MySQL, "", true},
+		{"table;DROP TABLE users", MySQL, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := QuoteDialect(tt.input, tt.dialect)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("QuoteDialect(%q, %v) error = %v, wantErr %v", tt.input, tt.dialect, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("QuoteDialect(%q, %v) = %q, want %q", tt.input, tt.dialect, got, tt.want)
+		}
+	}
+
+	if _, err := QuoteDialect("order", MySQL, RejectReserved()); err == nil {
+		t.Error("QuoteDialect with RejectReserved expected error for reserved word")
+	}
+}
+
+func TestQuoteWithStar(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"*", "*", false},
+		{"users.*", `"users".*`, false},
+		{"public.users.*", `"public"."users".*`, false},
+		{"public.users", `"public"."users"`, false},
+		{".*", "", true},
+		{"*.users", "", true},
+		{"public.*.users", "", true},
+		{"public.users.**", "", true},
+		{"public..*", "", true},
+		{"users*", "", true},
+		{"users.*;DROP TABLE x", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := QuoteWithStar(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("QuoteWithStar(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("QuoteWithStar(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteWithAlias(t *testing.T) {
+	tests := []struct {
+		col     string
+		alias   string
+		want    string
+		wantErr bool
+	}{
+		{"name", "n", `"name" AS "n"`, false},
+		{"public.users.name", "user_name", `"public"."users"."name" AS "user_name"`, false},
+		{"name", "", "", true},
+		{"name", "a.b", "", true}, // alias must be a single segment
+		{"name", `x" FROM users;--`, "", true},
+		{"name", "1alias", "", true},
+		{"na me", "n", "", true},
+		{"", "n", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := QuoteWithAlias(tt.col, tt.alias)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("QuoteWithAlias(%q, %q) error = %v, wantErr %v", tt.col, tt.alias, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("QuoteWithAlias(%q, %q) = %q, want %q", tt.col, tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteAll(t *testing.T) {
+	tests := []struct {
+		input   []string
+		want    []string
+		wantErr string
+	}{
+		{nil, []string{}, ""},
+		{[]string{}, []string{}, ""},
+		{[]string{"a"}, []string{`"a"`}, ""},
+		{[]string{"a", "schema.b"}, []string{`"a"`, `"schema"."b"`}, ""},
+		{[]string{"a", "b;DROP TABLE c", "d"}, nil, "index 1: invalid identifier: b;DROP TABLE c: bad character"},
+		{[]string{""}, nil, "index 0: invalid identifier: : empty segment"},
+	}
+
+	for _, tt := range tests {
+		got, err := QuoteAll(tt.input)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("QuoteAll(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("QuoteAll(%q) unexpected error = %v", tt.input, err)
+			continue
+		}
+		if !equalStringSlices(got, tt.want) {
+			t.Errorf("QuoteAll(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteJoin(t *testing.T) {
+	tests := []struct {
+		input   []string
+		sep     string
+		want    string
+		wantErr bool
+	}{
+		{nil, ",", "", false},
+		{[]string{"a", "b"}, ",", `"a","b"`, false},
+		{[]string{"a", "s.b"}, ", ", `"a", "s"."b"`, false},
+		{[]string{"a", "b'"}, ",", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := QuoteJoin(tt.input, tt.sep)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("QuoteJoin(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("QuoteJoin(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidIdentifierError(t *testing.T) {
+	long := strings.Repeat("a", 64)
+	tests := []struct {
+		input   string
+		segment string
+		reason  Reason
+	}{
+		{"", "", ReasonEmptySegment},
+		{"foo..bar", "", ReasonEmptySegment},
+		{"foo.", "", ReasonEmptySegment},
+		{"foo." + long, long, ReasonTooLong},
+		{"foo.bar-baz", "bar-baz", ReasonBadCharacter},
+		{"foo;DROP TABLE users", "foo;DROP TABLE users", ReasonBadCharacter},
+		{"schema.1abc", "1abc", ReasonStartsWithDigit},
+	}
+
+	for _, tt := range tests {
+		_, err := Quote(tt.input)
+		var ie *InvalidIdentifierError
+		if !errors.As(err, &ie) {
+			t.Errorf("Quote(%q) error = %v, want *InvalidIdentifierError", tt.input, err)
+			continue
+		}
+		if ie.Input != tt.input || ie.Segment != tt.segment || ie.Reason != tt.reason {
+			t.Errorf("Quote(%q) error = %+v, want segment %q reason %q", tt.input, ie, tt.segment, tt.reason)
+		}
+	}
+
+	_, err := SplitAndValidateCSV("id,foo..bar")
+	var ie *InvalidIdentifierError
+	if !errors.As(err, &ie) || ie.Input != "foo..bar" || ie.Reason != ReasonEmptySegment {
+		t.Errorf("SplitAndValidateCSV error = %v, want empty segment in foo..bar", err)
+	}
+
+	_, err = Quote("public.select", RejectReserved())
+	if !errors.As(err, &ie) || ie.Segment != "select" || ie.Reason != ReasonReserved {
+		t.Errorf("Quote(RejectReserved) error = %v, want reserved keyword select", err)
+	}
+
+	_, err = QuoteAll([]string{"a", "1b"})
+	if !errors.As(err, &ie) || ie.Reason != ReasonStartsWithDigit {
+		t.Errorf("QuoteAll error = %v, want starts with digit", err)
+	}
+
+	want := `invalid identifier: foo..bar: empty segment ""`
+	if _, err = Quote("foo..bar"); err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{`"table"`, []string{"table"}, false},
+		{`"schema"."table"`, []string{"schema", "table"}, false},
+		{`"a"."b"."c"`, []string{"a", "b", "c"}, false},
+		{`schema."table"`, []string{"schema", "table"}, false},
+		{`schema.table`, []string{"schema", "table"}, false},
+		{`"my.schema"."table"`, []string{"my.schema", "table"}, false}, // dot inside quotes
+		{`"foo""bar"`, []string{`foo"bar`}, false},                     // doubled quote collapsed
+		{`"foo bar"`, []string{"foo bar"}, false},
+		{"", nil, true},
+		{`"`, nil, true},               // unterminated quote
+		{`"table`, nil, true},          // unterminated quote
+		{`"schema"."table`, nil, true}, // unterminated quote in last segment
+		{`""`, nil, true},              // empty quoted segment
+		{`"schema".`, nil, true},       // trailing dot
+		{`."table"`, nil, true},        // leading dot
+		{`"schema".."table"`, nil, true},
+		{`"schema""table`, nil, true},
+		{`"schema"table`, nil, true}, // garbage after closing quote
+		{`"schema" ."table"`, nil, true},
+		{`foo-bar`, nil, true}, // unquoted segments must be valid identifiers
+		{`"a23456789012345678901234567890123456789012345678901234567890123"`, []string{"a23456789012345678901234567890123456789012345678901234567890123"}, false},
+		{`"a234567890123456789012345678901234567890123456789012345678901234"`, nil, true}, // 64 chars
+	}
+
+	for _, tt := range tests {
+		got, err := Unquote(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unquote(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !equalStringSlices(got, tt.want) {
+			t.Errorf("Unquote(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsQuoted(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`"users"`, true},
+		{`"public"."users"`, true},
+		{`"my ""odd"" table"`, true},
+		{`public."users"`, false},
+		{`public.users`, false},
+		{`users`, false},
+		{`"users`, false},
+		{`""`, false},
+		{`"a"b"`, false},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsQuoted(tt.input); got != tt.want {
+			t.Errorf("IsQuoted(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeQuoted(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{`"users"`, `"users"`, false},
+		{`"public"."users"`, `"public"."users"`, false},
+		{`"a""b"`, `"a""b"`, false},
+		{`users`, `"users"`, false},
+		{`public.users`, `"public"."users"`, false},
+		{`"public".users`, "", true}, // mixed quoting
+		{`public."users"`, "", true},
+		{`"users`, "", true},
+		{`"a"b"`, "", true},
+		{`"users";DROP TABLE x`, "", true},
+		{`""`, "", true},
+		{`"public".""`, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeQuoted(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeQuoted(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeQuoted(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	var ie *InvalidIdentifierError
+	if _, err := NormalizeQuoted(`"users`); !errors.As(err, &ie) || ie.Reason != ReasonUnterminatedQuote {
+		t.Errorf("NormalizeQuoted unterminated error = %v", err)
+	}
+}
+
+func TestUnquoteRoundTrip(t *testing.T) {
+	for _, s := range []string{"table", "schema.table", "a.b.c", "_foo._bar", "FooBar.baz_123"} {
+		quoted, err := Quote(s)
+		if err != nil {
+			t.Fatalf("Quote(%q) error = %v", s, err)
+		}
+		got, err := Unquote(quoted)
+		if err != nil {
+			t.Errorf("Unquote(%q) error = %v", quoted, err)
+			continue
+		}
+		if !equalStringSlices(got, strings.Split(s, ".")) {
+			t.Errorf("Unquote(Quote(%q)) = %v", s, got)
+		}
+	}
+}
+
 func TestSplitAndValidateCSV(t *testing.T) {
 	tests := []struct {
 		input   string
@@ -175,6 +556,46 @@ func TestSplitAndValidateCSV(t *testing.T) {
 	}
 }
 
+func TestSplitAndValidateCSVTrim(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"   ", nil, true}, // a single all-whitespace token is empty too
+		{"id, name , created_at", []string{"id", "name", "created_at"}, false},
+		{" schema.table ,foo.bar", []string{"schema.table", "foo.bar"}, false},
+		{"id,\tname\n", []string{"id", "name"}, false},
+		{"id, ,name", nil, true}, // all-whitespace token is empty
+		{"id,", nil, true},
+		{"id, na me", nil, true},
+		{"id, schema . table", nil, true},
+		{"id, name;DROP TABLE users", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitAndValidateCSVTrim(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitAndValidateCSVTrim(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !equalStringSlices(got, tt.want) {
+			t.Errorf("SplitAndValidateCSVTrim(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	var ie *InvalidIdentifierError
+	if _, err := SplitAndValidateCSVTrim("   "); !errors.As(err, &ie) {
+		t.Errorf("SplitAndValidateCSVTrim(\"   \") error = %v, want *InvalidIdentifierError", err)
+	}
+
+	// the untrimmed variant keeps rejecting whitespace
+	if _, err := SplitAndValidateCSV("id, name"); err == nil {
+		t.Error("SplitAndValidateCSV(\"id, name\") expected error")
+	}
+}
+
 // Helper for comparing slices
 func equalStringSlices(a, b []string) bool {
 	if len(a) != len(b) {
@@ -0,0 +1,55 @@
+package ident
+
+import (
+	"strings"
+	"sync"
+)
+
+// reservedKeywords holds the lower-cased reserved key words of PostgreSQL,
+// including those that can only be used as function or type names. Use
+// AddReservedKeywords to extend it with project specific words, reservedMu
+// guards it as words can be added while identifiers are checked.
+//
+// https://www.postgresql.org/docs/current/sql-keywords-appendix.html
+var (
+	reservedMu       sync.RWMutex
+	reservedKeywords = map[string]struct{}{}
+)
+
+func init() {
+	AddReservedKeywords(
+		"all", "analyse", "analyze", "and", "any", "array", "as", "asc",
+		"asymmetric", "authorization", "binary", "both", "case", "cast",
+		"check", "collate", "collation", "column", "concurrently",
+		"constraint", "create", "cross", "current_catalog", "current_date",
+		"current_role", "current_schema", "current_time", "current_timestamp",
+		"current_user", "default", "deferrable", "desc", "distinct", "do",
+		"else", "end", "except", "false", "fetch", "for", "foreign", "freeze",
+		"from", "full", "grant", "group", "having", "ilike", "in", "initially",
+		"inner", "intersect", "into", "is", "isnull", "join", "lateral",
+		"leading", "left", "like", "limit", "localtime", "localtimestamp",
+		"natural", "not", "notnull", "null", "offset", "on", "only", "or",
+		"order", "outer", "overlaps", "placing", "primary", "references",
+		"returning", "right", "select", "session_user", "similar", "some",
+		"symmetric", "system_user", "table", "tablesample", "then", "to",
+		"trailing", "true", "union", "unique", "user", "using", "variadic",
+		"verbose", "when", "where", "window", "with",
+	)
+}
+
+// AddReservedKeywords registers additional reserved words. Matching is case-insensitive.
+func AddReservedKeywords(words ...string) {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+	for _, w := range words {
+		reservedKeywords[strings.ToLower(w)] = struct{}{}
+	}
+}
+
+// IsReservedKeyword reports whether s, compared case-insensitively, is a reserved keyword.
+func IsReservedKeyword(s string) bool {
+	reservedMu.RLock()
+	_, ok := reservedKeywords[strings.ToLower(s)]
+	reservedMu.RUnlock()
+	return ok
+}
@@ -0,0 +1,100 @@
+package ident
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIsReservedKeyword(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"select", true},
+		{"SELECT", true},
+		{"Order", true},
+		{"user", true},
+		{"current_timestamp", true},
+		{"users", false},
+		{"orders", false},
+		{"", false},
+		{"public", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsReservedKeyword(tt.input); got != tt.want {
+			t.Errorf("IsReservedKeyword(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAddReservedKeywords(t *testing.T) {
+	t.Cleanup(func() {
+		reservedMu.Lock()
+		delete(reservedKeywords, "prest_reserved")
+		reservedMu.Unlock()
+	})
+
+	if IsReservedKeyword("prest_reserved") {
+		t.Fatal("prest_reserved should not be reserved by default")
+	}
+	AddReservedKeywords("PREST_Reserved")
+	if !IsReservedKeyword("prest_reserved") {
+		t.Error("prest_reserved should be reserved after AddReservedKeywords")
+	}
+}
+
+func TestAddReservedKeywordsConcurrent(t *testing.T) {
+	t.Cleanup(func() {
+		reservedMu.Lock()
+		delete(reservedKeywords, "prest_concurrent")
+		reservedMu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			AddReservedKeywords("prest_concurrent")
+		}()
+		go func() {
+			defer wg.Done()
+			IsReservedKeyword("select")
+		}()
+	}
+	wg.Wait()
+	if !IsReservedKeyword("prest_concurrent") {
+		t.Error("prest_concurrent should be reserved after AddReservedKeywords")
+	}
+}
+
+func TestQuoteRejectReserved(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"users", `"users"`, false},
+		{"public.users", `"public"."users"`, false},
+		{"select", "", true},
+		{"public.Order", "", true},
+		{"table.users", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Quote(tt.input, RejectReserved())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Quote(%q, RejectReserved()) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Quote(%q, RejectReserved()) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	// without the option reserved words are quoted as usual
+	if got, err := Quote("order"); err != nil || got != `"order"` {
+		t.Errorf("Quote(%q) = %q, %v", "order", got, err)
+	}
+}
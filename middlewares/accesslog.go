@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	pctx "github.com/prest/prest/v2/context"
+
+	"github.com/urfave/negroni/v3"
+)
+
+// NewAccessLogger returns a logger writing access log lines to w in the
+// given format, "text" or "json"
+func NewAccessLogger(w io.Writer, format string) (*slog.Logger, error) {
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	}
+	return nil, fmt.Errorf("unknown access log format %q", format)
+}
+
+// AccessLogger logs the requests of the default middleware stack, set it
+// before GetApp to log elsewhere. When nil GetApp logs to stdout in the
+// configured access log format
+var AccessLogger *slog.Logger
+
+// AccessLog logs one line per request with logger, it runs first in the
+// middleware stack and logs the request id when the app runs inside RequestID
+func AccessLog(logger *slog.Logger) negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		start := time.Now()
+		nrw := negroni.NewResponseWriter(rw)
+		next(nrw, r)
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", nrw.Status()),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", nrw.Size()),
+			slog.String("request_id", pctx.RequestIDFromContext(r.Context())),
+		)
+	})
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewAccessLogger(&buf, "json")
+	require.NoError(t, err)
+
+	h := negroni.New(AccessLog(logger))
+	h.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout")) // nolint
+	})
+	req := httptest.NewRequest(http.MethodGet, "/db/public/table?_page=1", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	RequestID(h).ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "request", line["msg"])
+	require.Equal(t, "GET", line["method"])
+	require.Equal(t, "/db/public/table", line["path"])
+	require.Equal(t, float64(http.StatusTeapot), line["status"])
+	require.Equal(t, float64(15), line["bytes"])
+	require.Equal(t, "abc", line["request_id"])
+	require.Contains(t, line, "duration")
+}
+
+func TestNewAccessLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewAccessLogger(&buf, "text")
+	require.NoError(t, err)
+	logger.Info("request", "status", 200)
+	require.Contains(t, buf.String(), "msg=request status=200")
+
+	_, err = NewAccessLogger(&buf, "xml")
+	require.Error(t, err)
+}
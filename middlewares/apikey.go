@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+
+	"github.com/urfave/negroni/v3"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+var ErrAPIKeyInvalid = errors.New("invalid API key")
+
+// APIKey authenticates the requests carrying an X-API-Key header as the role
+// of the matching key, unknown keys are rejected with 401. Requests without
+// the header are left to the other authentication middlewares
+func APIKey(keys []config.APIKeyConf) negroni.Handler {
+	byHash := apiKeysByHash(keys)
+	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		k, ok := byHash.lookup(key)
+		if !ok {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrAPIKeyInvalid.Error()), http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), pctx.APIKeyKey, k.Name)
+		ctx = withUserInfo(ctx, auth.User{Name: k.Name, Username: k.Role})
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// apiKeyAuthenticated tells whether APIKey already authenticated r
+func apiKeyAuthenticated(r *http.Request) bool {
+	_, ok := r.Context().Value(pctx.APIKeyKey).(string)
+	return ok
+}
+
+// apiKeyHashes indexes the configured keys by their lowercase hex SHA-256
+type apiKeyHashes map[string]config.APIKeyConf
+
+func apiKeysByHash(keys []config.APIKeyConf) apiKeyHashes {
+	byHash := make(apiKeyHashes, len(keys))
+	for _, k := range keys {
+		hash, err := hex.DecodeString(k.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			slog.Error("api key hash is not a hex SHA-256, key ignored", "name", k.Name)
+			continue
+		}
+		byHash[strings.ToLower(k.Hash)] = k
+	}
+	return byHash
+}
+
+// lookup returns the configured key matching the raw key of a request
+func (h apiKeyHashes) lookup(key string) (config.APIKeyConf, bool) {
+	sum := sha256.Sum256([]byte(key))
+	k, ok := h[hex.EncodeToString(sum[:])]
+	return k, ok
+}
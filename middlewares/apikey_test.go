@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func apiKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAPIKey(t *testing.T) {
+	n := negroni.New(APIKey([]config.APIKeyConf{
+		{Name: "billing-2024", Hash: apiKeyHash("old-key"), Role: "billing"},
+		{Name: "billing-2025", Hash: apiKeyHash("new-key"), Role: "billing"},
+		{Name: "broken", Hash: "not-a-hash", Role: "admin"},
+	}), VerifyToken(staticVerifier{}))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(pctx.UserInfoKey).(auth.User)
+		w.Write([]byte(user.Name + " " + user.Username))
+	})
+
+	var testCases = []struct {
+		description string
+		key         string
+		status      int
+		body        string
+	}{
+		{"current key", "new-key", http.StatusOK, "billing-2025 billing"},
+		{"key being rotated out", "old-key", http.StatusOK, "billing-2024 billing"},
+		{"unknown key", "other-key", http.StatusUnauthorized, ""},
+		{"hash given as key", apiKeyHash("new-key"), http.StatusUnauthorized, ""},
+		{"no key falls back to the JWT", "", http.StatusUnauthorized, ErrAuthIsEmpty.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/prest-test/public/test", nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			n.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code)
+			require.Contains(t, rec.Body.String(), tc.body)
+		})
+	}
+}
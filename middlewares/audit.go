@@ -0,0 +1,200 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+
+	"github.com/urfave/negroni/v3"
+)
+
+// AuditEntry records a write request
+type AuditEntry struct {
+	Time      time.Time       `json:"time"`
+	Principal string          `json:"principal,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Action    string          `json:"action"`
+	Path      string          `json:"path"`
+	Database  string          `json:"database,omitempty"`
+	Schema    string          `json:"schema,omitempty"`
+	Table     string          `json:"table,omitempty"`
+	Status    int             `json:"status"`
+	RequestID string          `json:"request_id,omitempty"`
+	Body      json.RawMessage `json:"body,omitempty"`
+}
+
+// AuditSink stores audit entries, it is called from a single goroutine
+type AuditSink interface {
+	Record(entry AuditEntry) error
+}
+
+// Auditor replaces the audit destination of the default middleware stack,
+// set it before GetApp
+var Auditor AuditSink
+
+// LogAuditSink writes the entries as structured log records
+type LogAuditSink struct {
+	Logger *slog.Logger
+}
+
+// Record implements AuditSink
+func (s LogAuditSink) Record(e AuditEntry) error {
+	s.Logger.Info("audit",
+		slog.Time("time", e.Time),
+		slog.String("principal", e.Principal),
+		slog.String("role", e.Role),
+		slog.String("action", e.Action),
+		slog.String("path", e.Path),
+		slog.String("database", e.Database),
+		slog.String("schema", e.Schema),
+		slog.String("table", e.Table),
+		slog.Int("status", e.Status),
+		slog.String("request_id", e.RequestID),
+		slog.String("body", string(e.Body)),
+	)
+	return nil
+}
+
+// auditColumns are the columns TableAuditSink inserts into, e.g.
+//
+//	CREATE TABLE audit_log(
+//	    id bigserial PRIMARY KEY, "time" timestamptz, principal text,
+//	    role text, action text, path text, "database" text, "schema" text,
+//	    "table" text, status int, request_id text, body jsonb);
+var auditColumns = []string{"time", "principal", "role", "action", "path", "database", "schema", "table", "status", "request_id", "body"}
+
+// TableAuditSink inserts the entries into a table of the default database,
+// granting the prestd role INSERT only on it keeps the trail append-only
+type TableAuditSink struct {
+	Schema string
+	Table  string
+}
+
+// Record implements AuditSink
+func (s TableAuditSink) Record(e AuditEntry) error {
+	var body interface{}
+	if len(e.Body) > 0 {
+		body = string(e.Body)
+	}
+	placeholders := make([]string, len(auditColumns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	adapter := config.PrestConf.Adapter
+	SQL := adapter.InsertSQL(adapter.GetDatabase(), s.Schema, s.Table,
+		`"`+strings.Join(auditColumns, `","`)+`"`, "("+strings.Join(placeholders, ",")+")")
+	sc := adapter.Insert(SQL, e.Time, e.Principal, e.Role, e.Action, e.Path, e.Database, e.Schema, e.Table, e.Status, e.RequestID, body)
+	return sc.Err()
+}
+
+// Audit records the POST, PUT, PATCH and DELETE requests in sink. Entries
+// are queued and recorded in the background, when queueSize entries are
+// pending new ones are dropped rather than slowing requests down. The
+// principal is the user set by any authentication middleware, before or
+// after it, that uses withUserInfo. bodyLimit is the largest request body
+// recorded in bytes, larger requests are rejected, and 0 leaves bodies out
+func Audit(sink AuditSink, queueSize int, bodyLimit int64) negroni.Handler {
+	entries := make(chan AuditEntry, queueSize)
+	go func() {
+		for e := range entries {
+			if err := sink.Record(e); err != nil {
+				slog.Error("could not record audit entry", "path", e.Path, "err", err)
+			}
+		}
+	}()
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next(rw, r)
+			return
+		}
+		e := AuditEntry{
+			Time:      time.Now(),
+			Action:    r.Method,
+			Path:      r.URL.Path,
+			RequestID: pctx.RequestIDFromContext(r.Context()),
+		}
+		// the router authenticates after Audit, withUserInfo reports the
+		// principal back through the context like RouteLabel does for Metrics
+		var principal auditPrincipal
+		if user, ok := r.Context().Value(pctx.UserInfoKey).(auth.User); ok {
+			principal = principalOf(r.Context(), user)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), pctx.AuditPrincipalKey, &principal))
+		if vars := getVars(r.URL.Path); vars != nil {
+			e.Database, e.Schema, e.Table = vars["database"], vars["schema"], vars["table"]
+		}
+		if bodyLimit > 0 && r.Body != nil {
+			body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, bodyLimit))
+			r.Body.Close()
+			if err != nil {
+				status := http.StatusBadRequest
+				if _, ok := err.(*http.MaxBytesError); ok {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(rw, fmt.Sprintf(jsonErrFormat, err.Error()), status)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			e.Body = auditBody(body)
+		}
+
+		nrw := negroni.NewResponseWriter(rw)
+		next(nrw, r)
+
+		e.Principal, e.Role = principal.name, principal.role
+		e.Status = nrw.Status()
+		select {
+		case entries <- e:
+		default:
+			slog.Warn("audit queue full, entry dropped", "path", e.Path)
+		}
+	})
+}
+
+// auditBody keeps JSON bodies as they are and stores the others as a string
+func auditBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// auditPrincipal is who made an audited request
+type auditPrincipal struct {
+	name string
+	role string
+}
+
+// principalOf returns the audit principal of user, API keys are recorded by
+// name with their role apart as every key of a role shares the username
+func principalOf(ctx context.Context, user auth.User) auditPrincipal {
+	if name, ok := ctx.Value(pctx.APIKeyKey).(string); ok {
+		return auditPrincipal{name: name, role: user.Username}
+	}
+	return auditPrincipal{name: user.Username}
+}
+
+// withUserInfo sets the authenticated user of a request and reports it to
+// Audit when it runs ahead of the authentication
+func withUserInfo(ctx context.Context, user auth.User) context.Context {
+	if principal, ok := ctx.Value(pctx.AuditPrincipalKey).(*auditPrincipal); ok {
+		*principal = principalOf(ctx, user)
+	}
+	return context.WithValue(ctx, pctx.UserInfoKey, user)
+}
@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+type chanAuditSink chan AuditEntry
+
+func (s chanAuditSink) Record(e AuditEntry) error {
+	s <- e
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	sink := make(chanAuditSink, 1)
+	n := negroni.New(
+		negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			next(rw, r.WithContext(context.WithValue(r.Context(), pctx.UserInfoKey, auth.User{Username: "gopher"})))
+		}),
+		Audit(sink, 10, 1<<10),
+	)
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+
+	rec := httptest.NewRecorder()
+	n.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/prest-test/public/test", strings.NewReader(`{"name":"gopher"}`)))
+	require.Equal(t, `{"name":"gopher"}`, rec.Body.String(), "the handler still reads the body")
+
+	select {
+	case e := <-sink:
+		require.Equal(t, "gopher", e.Principal)
+		require.Equal(t, http.MethodPost, e.Action)
+		require.Equal(t, "prest-test", e.Database)
+		require.Equal(t, "public", e.Schema)
+		require.Equal(t, "test", e.Table)
+		require.Equal(t, http.StatusCreated, e.Status)
+		require.JSONEq(t, `{"name":"gopher"}`, string(e.Body))
+	case <-time.After(time.Second):
+		t.Fatal("no audit entry recorded")
+	}
+
+	n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/prest-test/public/test", nil))
+	select {
+	case e := <-sink:
+		t.Fatalf("read recorded: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAuditAuthenticatedDownstream(t *testing.T) {
+	sink := make(chanAuditSink, 1)
+	audit := Audit(sink, 10, 0)
+	// the router authenticates after Audit and a plain http.Handler sits in
+	// front of it
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(withUserInfo(r.Context(), auth.User{Username: "gopher"}))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/prest-test/public/test", nil))
+	select {
+	case e := <-sink:
+		require.Equal(t, "gopher", e.Principal)
+		require.Equal(t, http.StatusNoContent, e.Status)
+	case <-time.After(time.Second):
+		t.Fatal("no audit entry recorded")
+	}
+}
+
+func TestAuditAPIKeyPrincipal(t *testing.T) {
+	sink := make(chanAuditSink, 2)
+	n := negroni.New(Audit(sink, 10, 0), APIKey([]config.APIKeyConf{
+		{Name: "billing-2024", Hash: apiKeyHash("old-key"), Role: "billing"},
+		{Name: "billing-2025", Hash: apiKeyHash("new-key"), Role: "billing"},
+	}))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, key := range []string{"old-key", "new-key"} {
+		r := httptest.NewRequest(http.MethodDelete, "/prest-test/public/test", nil)
+		r.Header.Set(apiKeyHeader, key)
+		n.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	for _, name := range []string{"billing-2024", "billing-2025"} {
+		select {
+		case e := <-sink:
+			require.Equal(t, name, e.Principal)
+			require.Equal(t, "billing", e.Role)
+		case <-time.After(time.Second):
+			t.Fatal("no audit entry recorded")
+		}
+	}
+}
+
+func TestAuditBodyTooLarge(t *testing.T) {
+	sink := make(chanAuditSink, 1)
+	n := negroni.New(Audit(sink, 10, 8))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("oversized request reached the handler")
+	})
+
+	rec := httptest.NewRecorder()
+	n.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/prest-test/public/test", strings.NewReader(`{"name":"gopher"}`)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+type blockingAuditSink chan struct{}
+
+func (s blockingAuditSink) Record(AuditEntry) error {
+	<-s
+	return nil
+}
+
+func TestAuditQueueFull(t *testing.T) {
+	sink := make(blockingAuditSink)
+	defer close(sink)
+	n := negroni.New(Audit(sink, 1, 0))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/prest-test/public/test", nil))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("requests blocked on a slow audit sink")
+	}
+}
+
+func TestAuditBody(t *testing.T) {
+	require.Nil(t, auditBody(nil))
+	require.Equal(t, json.RawMessage(`[1,2]`), auditBody([]byte(`[1,2]`)))
+	require.Equal(t, json.RawMessage(`"name=gopher"`), auditBody([]byte(`name=gopher`)))
+}
@@ -1,10 +1,17 @@
 package middlewares
 
 import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
 	"github.com/rs/cors"
 	"github.com/urfave/negroni/v3"
 
 	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/internal/ident"
+	"github.com/prest/prest/v2/template"
 )
 
 var (
@@ -16,16 +23,57 @@ var (
 	// BaseStack Middlewares
 	BaseStack = []negroni.Handler{
 		negroni.Handler(negroni.NewRecovery()),
-		negroni.Handler(negroni.NewLogger()),
 		HandlerSet(),
 		SetTimeoutToContext(),
+		RequestTimeout(),
+		UsePrimary(),
+		ReadOnly(),
 	}
 )
 
+// accessLogger returns AccessLogger or, when unset, a logger to stdout in
+// the configured format
+func accessLogger() *slog.Logger {
+	if AccessLogger != nil {
+		return AccessLogger
+	}
+	logger, err := NewAccessLogger(os.Stdout, config.PrestConf.AccessLogFormat)
+	if err != nil {
+		slog.Error("invalid access log format, logging as text", "err", err)
+		logger, _ = NewAccessLogger(os.Stdout, "text")
+	}
+	return logger
+}
+
 func initApp() {
+	template.MaxPageSize = config.PrestConf.PaginationMaxPageSize
 	if len(MiddlewareStack) == 0 {
+		// log the response as sent, compressed
+		MiddlewareStack = append(MiddlewareStack, AccessLog(accessLogger()))
+		if config.PrestConf.GzipEnabled {
+			// compress the output rendered by HandlerSet
+			MiddlewareStack = append(MiddlewareStack, Gzip(config.PrestConf.GzipMinLength))
+		}
+		if config.PrestConf.ETagEnabled {
+			// hash the rendered, uncompressed body
+			MiddlewareStack = append(MiddlewareStack, ETag(config.PrestConf.ETagMaxSize))
+		}
 		MiddlewareStack = append(MiddlewareStack, BaseStack...)
-		if config.PrestConf.CORSAllowOrigin != nil {
+		if config.PrestConf.MetricsEnabled {
+			MiddlewareStack = append(MiddlewareStack, Metrics())
+		}
+		if config.PrestConf.RateLimitEnabled {
+			key := ClientIP
+			switch header := config.PrestConf.RateLimitHeader; {
+			case http.CanonicalHeaderKey(header) == apiKeyHeader:
+				key = APIKeyName(config.PrestConf.APIKeys)
+			case header != "":
+				key = HeaderKey(header)
+			}
+			MiddlewareStack = append(MiddlewareStack,
+				RateLimit(config.PrestConf.RateLimitRPS, config.PrestConf.RateLimitBurst, key))
+		}
+		if config.PrestConf.CORSEnabled && len(config.PrestConf.CORSAllowOrigin) > 0 {
 			MiddlewareStack = append(
 				MiddlewareStack,
 				cors.New(cors.Options{
@@ -35,10 +83,23 @@ func initApp() {
 					AllowCredentials: config.PrestConf.CORSAllowCredentials,
 				}))
 		}
+		if config.PrestConf.APIKeyEnabled {
+			// authenticate API keys before the JWT middleware
+			MiddlewareStack = append(MiddlewareStack, APIKey(config.PrestConf.APIKeys))
+		}
 		if !config.PrestConf.Debug && config.PrestConf.EnableDefaultJWT {
-			MiddlewareStack = append(
-				MiddlewareStack,
-				JwtMiddleware(config.PrestConf.JWTKey, config.PrestConf.JWTJWKS, config.PrestConf.JWTAlgo))
+			jwtMiddleware := JwtMiddleware(config.PrestConf.JWTKey, config.PrestConf.JWTJWKS, config.PrestConf.JWTAlgo)
+			if Verifier != nil {
+				jwtMiddleware = VerifyToken(Verifier)
+			}
+			MiddlewareStack = append(MiddlewareStack, jwtMiddleware)
+		}
+		if config.PrestConf.AuditEnabled {
+			var bodyLimit int64
+			if config.PrestConf.AuditBody {
+				bodyLimit = config.PrestConf.AuditBodyMaxSize
+			}
+			MiddlewareStack = append(MiddlewareStack, Audit(auditSink(), config.PrestConf.AuditQueueSize, bodyLimit))
 		}
 		if config.PrestConf.Cache.Enabled {
 			MiddlewareStack = append(MiddlewareStack, CacheMiddleware(&config.PrestConf.Cache))
@@ -50,6 +111,20 @@ func initApp() {
 	app = negroni.New(MiddlewareStack...)
 }
 
+// auditSink returns Auditor or else the configured audit destination
+func auditSink() AuditSink {
+	if Auditor != nil {
+		return Auditor
+	}
+	if schema, table, ok := strings.Cut(config.PrestConf.AuditTable, "."); ok && ident.IsSegment(schema) && ident.IsSegment(table) {
+		return TableAuditSink{Schema: schema, Table: table}
+	}
+	if config.PrestConf.AuditTable != "" {
+		slog.Error("audit table must be a valid schema.table, logging the audit entries instead", "table", config.PrestConf.AuditTable)
+	}
+	return LogAuditSink{Logger: slog.Default()}
+}
+
 // GetApp get negroni
 func GetApp() *negroni.Negroni {
 	if app == nil {
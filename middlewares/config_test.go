@@ -1,8 +1,10 @@
 package middlewares
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +12,7 @@ import (
 	"github.com/prest/prest/v2/adapters/postgres"
 	"github.com/prest/prest/v2/config"
 	"github.com/prest/prest/v2/controllers"
+	"github.com/prest/prest/v2/template"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
@@ -29,6 +32,19 @@ func TestInitApp(t *testing.T) {
 	MiddlewareStack = []negroni.Handler{}
 }
 
+func TestInitAppMaxPageSize(t *testing.T) {
+	defer func(size int) {
+		config.PrestConf.PaginationMaxPageSize, template.MaxPageSize = size, size
+	}(config.PrestConf.PaginationMaxPageSize)
+	config.PrestConf.PaginationMaxPageSize = 100
+
+	app = nil
+	initApp()
+	require.Equal(t, 100, template.MaxPageSize)
+
+	MiddlewareStack = []negroni.Handler{}
+}
+
 func TestGetApp(t *testing.T) {
 	app = nil
 	require.NotNil(t, GetApp())
@@ -36,6 +52,23 @@ func TestGetApp(t *testing.T) {
 	MiddlewareStack = []negroni.Handler{}
 }
 
+func TestGetAppLogsRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewAccessLogger(&buf, "json")
+	require.NoError(t, err)
+	defer func(l *slog.Logger) { AccessLogger = l }(AccessLogger)
+	AccessLogger = logger
+
+	app = nil
+	MiddlewareStack = []negroni.Handler{}
+	n := GetApp()
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/databases", nil))
+	require.Contains(t, buf.String(), `"path":"/databases"`, "embedders get the access log without cmd")
+
+	MiddlewareStack = []negroni.Handler{}
+}
+
 func TestGetAppWithReorderedMiddleware(t *testing.T) {
 	app = nil
 	MiddlewareStack = []negroni.Handler{
@@ -279,6 +312,28 @@ func Test_CORS_Middleware(t *testing.T) {
 	require.Zero(t, len(body))
 }
 
+func Test_CORS_Middleware_Disabled(t *testing.T) {
+	MiddlewareStack = []negroni.Handler{}
+	t.Setenv("PREST_DEBUG", "true")
+	t.Setenv("PREST_CORS_ENABLED", "false")
+	t.Setenv("PREST_CONF", "../testdata/prest.toml")
+	config.Load()
+	app = nil
+	r := mux.NewRouter()
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("custom route")) })
+	n := GetApp()
+	n.UseHandler(r)
+	server := httptest.NewServer(n)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
 func TestExposeTablesMiddleware(t *testing.T) {
 	MiddlewareStack = []negroni.Handler{}
 	app = nil
@@ -0,0 +1,307 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errCSVShape = errors.New("csv output needs a JSON array of objects")
+
+// responseFormat picks the renderer from the _renderer or _format query
+// parameters, or from an Accept header preferring text/csv
+func responseFormat(r *http.Request) string {
+	q := r.URL.Query()
+	if f := q.Get("_renderer"); f != "" {
+		return f
+	}
+	if f := q.Get("_format"); f != "" {
+		return f
+	}
+	if acceptsCSV(r.Header.Get("Accept")) {
+		return "csv"
+	}
+	return ""
+}
+
+// acceptsCSV tells whether accept ranks text/csv above zero and at least as
+// high as application/json and */*
+func acceptsCSV(accept string) bool {
+	var csvQ, jsonQ float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/csv":
+			csvQ = max(csvQ, q)
+		case "application/json", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return csvQ > 0 && csvQ >= jsonQ
+}
+
+// csvDelimiter returns the first rune of delim, defaulting to a comma
+func csvDelimiter(delim string) rune {
+	if r, _ := utf8.DecodeRuneInString(delim); r != utf8.RuneError {
+		return r
+	}
+	return ','
+}
+
+// jsonToCSV converts a JSON array of objects read from r into RFC 4180 CSV,
+// writing each row to w as soon as it is decoded. The header row lists the
+// keys of the first object in order, keys missing from later rows are left
+// empty and extra keys are dropped
+func jsonToCSV(w io.Writer, r io.Reader, delim rune) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return errCSVShape
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = delim
+	cw.UseCRLF = true
+
+	var header []string
+	for dec.More() {
+		keys, values, err := decodeRow(dec)
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			header = keys
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+		record := make([]string, len(header))
+		for i, k := range header {
+			record[i] = values[k]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		// hand each row to w rather than buffering the result
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeRow reads the next object of dec keeping the order of its keys
+func decodeRow(dec *json.Decoder) (keys []string, values map[string]string, err error) {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, errCSVShape
+	}
+	values = make(map[string]string)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values[key] = csvValue(raw)
+	}
+	_, err = dec.Token() // closing brace
+	return keys, values, err
+}
+
+// csvValue renders strings unquoted, null as an empty field and anything
+// else, including nested objects, as compact JSON
+func csvValue(raw json.RawMessage) string {
+	var s string
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return ""
+	case json.Unmarshal(raw, &s) == nil:
+		return s
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// csvResponseWriter converts the JSON arrays written by a handler to CSV as
+// they are written, so rows reach the client while the handler writes the
+// next ones. Error responses are buffered and rendered by renderFormat, and
+// bodies that are not arrays, such as a single object, are passed through.
+// The headers are sent from the handler goroutine once the first bytes show
+// the shape of the body, the conversion goroutine only writes the rows
+type csvResponseWriter struct {
+	w         http.ResponseWriter
+	delim     rune
+	requestID string
+
+	code   int
+	head   []byte
+	errBuf *httptest.ResponseRecorder
+	// once the shape is known the body goes through pw, straight to w or,
+	// when rejected, nowhere
+	pw          *io.PipeWriter
+	done        chan struct{}
+	passthrough bool
+	rejected    bool
+}
+
+func newCSVResponseWriter(w http.ResponseWriter, delim rune, requestID string) *csvResponseWriter {
+	return &csvResponseWriter{w: w, delim: delim, requestID: requestID}
+}
+
+func (c *csvResponseWriter) Header() http.Header {
+	return c.w.Header()
+}
+
+func (c *csvResponseWriter) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+}
+
+func (c *csvResponseWriter) Write(p []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	switch {
+	case c.code >= 400:
+		if c.errBuf == nil {
+			c.errBuf = httptest.NewRecorder()
+			c.errBuf.WriteHeader(c.code)
+		}
+		return c.errBuf.Write(p)
+	case c.pw != nil:
+		return c.pw.Write(p)
+	case c.passthrough:
+		return c.w.Write(p)
+	case c.rejected:
+		return len(p), nil
+	}
+	c.head = append(c.head, p...)
+	if !c.start() {
+		return len(p), nil
+	}
+	head := c.head
+	c.head = nil
+	var err error
+	switch {
+	case c.pw != nil:
+		_, err = c.pw.Write(head)
+	case c.passthrough:
+		_, err = c.w.Write(head)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start sends the headers once the buffered head shows whether the body is
+// an array of objects, it returns false while more bytes are needed
+func (c *csvResponseWriter) start() bool {
+	first := firstNonSpace(c.head, 0)
+	if first < 0 {
+		return false
+	}
+	if c.head[first] != '[' {
+		c.startJSON()
+		return true
+	}
+	second := firstNonSpace(c.head, first+1)
+	if second < 0 {
+		return false
+	}
+	switch c.head[second] {
+	case '{', ']':
+		c.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		c.w.Header().Del("Content-Length")
+		c.w.WriteHeader(c.code)
+		pr, pw := io.Pipe()
+		c.pw, c.done = pw, make(chan struct{})
+		go func() {
+			defer close(c.done)
+			// unblock the handler when the conversion stops early
+			pr.CloseWithError(c.convert(pr))
+		}()
+	default:
+		c.rejected = true
+		c.w.Header().Set("Content-Type", "application/json")
+		c.w.Header().Del("Content-Length")
+		c.w.WriteHeader(http.StatusNotAcceptable)
+		fmt.Fprintf(c.w, jsonErrFormat, errCSVShape.Error())
+	}
+	return true
+}
+
+// startJSON passes a body that can't be converted through as it is
+func (c *csvResponseWriter) startJSON() {
+	c.passthrough = true
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(c.code)
+}
+
+// close ends the response once the handler returned
+func (c *csvResponseWriter) close() {
+	switch {
+	case c.errBuf != nil:
+		renderFormat(c.w, c.errBuf, "", c.requestID)
+	case c.pw != nil:
+		c.pw.Close()
+		<-c.done
+	case c.passthrough, c.rejected:
+	case len(c.head) > 0:
+		// too short to tell its shape, a blank or truncated body
+		c.startJSON()
+		c.w.Write(c.head)
+	default:
+		c.w.WriteHeader(max(c.code, http.StatusOK))
+	}
+}
+
+// convert writes the JSON array of objects read from r as CSV, the headers
+// are already sent so a later error can only end the body early
+func (c *csvResponseWriter) convert(r io.Reader) error {
+	err := jsonToCSV(c.w, r, c.delim)
+	if err != nil {
+		slog.Error("could not render csv", "err", err)
+	}
+	return err
+}
+
+// firstNonSpace returns the index of the first byte of b past from that is
+// not white space, -1 when there is none
+func firstNonSpace(b []byte, from int) int {
+	for i := from; i < len(b); i++ {
+		if !unicode.IsSpace(rune(b[i])) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,203 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestJSONToCSV(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		json  string
+		delim rune
+		csv   string
+		err   bool
+	}{
+		{
+			name:  "keeps column order",
+			json:  `[{"name": "ada", "id": 1}, {"name": "grace", "id": 2}]`,
+			delim: ',',
+			csv:   "name,id\r\nada,1\r\ngrace,2\r\n",
+		},
+		{
+			name:  "quotes and escapes",
+			json:  `[{"note": "a, \"quoted\"\nline", "n": null, "tags": ["x", "y"], "ok": true}]`,
+			delim: ',',
+			csv:   "note,n,tags,ok\r\n\"a, \"\"quoted\"\"\r\nline\",,\"[\"\"x\"\",\"\"y\"\"]\",true\r\n",
+		},
+		{
+			name:  "missing and extra keys",
+			json:  `[{"a": 1, "b": 2}, {"b": 3, "c": 4}]`,
+			delim: ';',
+			csv:   "a;b\r\n1;2\r\n;3\r\n",
+		},
+		{name: "empty", json: `[]`, delim: ',', csv: ""},
+		{name: "not an array", json: `{"a": 1}`, delim: ',', err: true},
+		{name: "array of scalars", json: `[1, 2]`, delim: ',', err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := jsonToCSV(&buf, strings.NewReader(tc.json), tc.delim)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.csv, buf.String())
+		})
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/db/public/t?_format=csv", nil)
+	require.Equal(t, "csv", responseFormat(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/db/public/t?_renderer=xml", nil)
+	req.Header.Set("Accept", "text/csv")
+	require.Equal(t, "xml", responseFormat(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/db/public/t", nil)
+	req.Header.Set("Accept", "text/csv, application/json;q=0.5")
+	require.Equal(t, "csv", responseFormat(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/db/public/t", nil)
+	req.Header.Set("Accept", "text/csv;q=0")
+	require.Empty(t, responseFormat(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/db/public/t", nil)
+	require.Empty(t, responseFormat(req))
+}
+
+func TestAcceptsCSV(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"text/csv":                               true,
+		"Text/CSV; charset=utf-8":                true,
+		"text/csv, application/json;q=0.5":       true,
+		"application/json;q=0.5, text/csv;q=0.8": true,
+		"text/csv;q=0.5, */*;q=0.5":              true,
+		"text/csv;q=0":                           false,
+		"text/csv;q=0.0, application/json":       false,
+		"application/json, text/csv;q=0.9":       false,
+		"*/*, text/csv;q=0.5":                    false,
+		"text/csv;q=oops":                        false,
+		"*/*":                                    false,
+		"":                                       false,
+	} {
+		require.Equal(t, expected, acceptsCSV(accept), accept)
+	}
+}
+
+func TestHandlerSetCSV(t *testing.T) {
+	serve := func(code int, body string) *httptest.ResponseRecorder {
+		n := negroni.New(HandlerSet())
+		n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Total-Count", "2")
+			w.WriteHeader(code)
+			w.Write([]byte(body))
+		})
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/db/public/t?_format=csv", nil))
+		return rec
+	}
+
+	rec := serve(http.StatusOK, `[{"id": 1, "name": "ada"}, {"id": 2, "name": "grace"}]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+	require.Equal(t, "id,name\r\n1,ada\r\n2,grace\r\n", rec.Body.String())
+
+	rec = serve(http.StatusOK, `[]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Empty(t, rec.Body.String())
+
+	rec = serve(http.StatusOK, `[1, 2]`)
+	require.Equal(t, http.StatusNotAcceptable, rec.Code)
+	require.Contains(t, rec.Body.String(), errCSVShape.Error())
+
+	rec = serve(http.StatusCreated, `{"id": 1}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"id": 1}`, rec.Body.String())
+
+	rec = serve(http.StatusBadRequest, "invalid column")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `"error": "invalid column"`)
+}
+
+// syncRecorder is a ResponseRecorder safe to read while the CSV rows are
+// being written from another goroutine
+type syncRecorder struct {
+	mu sync.Mutex
+	*httptest.ResponseRecorder
+}
+
+func (s *syncRecorder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ResponseRecorder.Write(p)
+}
+
+func (s *syncRecorder) body() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ResponseRecorder.Body.String()
+}
+
+func TestHandlerSetCSVStreams(t *testing.T) {
+	rec := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	n := negroni.New(HandlerSet())
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": 1},`))
+		require.Eventually(t, func() bool { return rec.body() == "id\r\n1\r\n" },
+			time.Second, time.Millisecond, "first row sent before the handler wrote the second")
+		// the rows are converted in another goroutine, which leaves the
+		// headers to the handler
+		w.Header().Set("X-Late", "1")
+		w.Write([]byte(`{"id": 2}]`))
+	})
+	n.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/db/public/t?_format=csv", nil))
+	require.Equal(t, "id\r\n1\r\n2\r\n", rec.body())
+	require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestHandlerSetCSVSplitWrites(t *testing.T) {
+	serve := func(chunks ...string) *httptest.ResponseRecorder {
+		n := negroni.New(HandlerSet())
+		n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, c := range chunks {
+				w.Write([]byte(c))
+			}
+		})
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/db/public/t?_format=csv", nil))
+		return rec
+	}
+
+	rec := serve(" \n", "[", " ", `{"id": 1}]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "id\r\n1\r\n", rec.Body.String())
+
+	rec = serve("[", "1, 2]")
+	require.Equal(t, http.StatusNotAcceptable, rec.Code)
+	require.Contains(t, rec.Body.String(), errCSVShape.Error())
+
+	rec = serve("[")
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.Equal(t, "[", rec.Body.String())
+}
+
+func TestCSVDelimiter(t *testing.T) {
+	require.Equal(t, ',', csvDelimiter(""))
+	require.Equal(t, ';', csvDelimiter(";"))
+	require.Equal(t, '\t', csvDelimiter("\t"))
+}
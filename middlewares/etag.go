@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/negroni/v3"
+)
+
+// ETag sets a weak ETag, hashed from the body, on successful GET and HEAD
+// responses and answers 304 Not Modified when it matches If-None-Match.
+// Responses over maxSize bytes, flushed while streaming or sent as
+// attachments are passed through without one
+func ETag(maxSize int) negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead || isUpgrade(r) {
+			next(rw, r)
+			return
+		}
+		ew := &etagWriter{ResponseWriter: rw, maxSize: maxSize, status: http.StatusOK}
+		next(ew, r)
+		if ew.started {
+			return
+		}
+		sum := sha256.Sum256(ew.buf)
+		tag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		rw.Header().Set("ETag", tag)
+		if etagMatch(r.Header.Get("If-None-Match"), tag) {
+			rw.Header().Del("Content-Length")
+			rw.Header().Del("Content-Type")
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		ew.start() // nolint
+	})
+}
+
+// etagWriter holds back a 200 response to hash it, anything it can't tag
+// goes straight to the ResponseWriter
+type etagWriter struct {
+	http.ResponseWriter
+	maxSize int
+	status  int
+	buf     []byte
+	started bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.started {
+		return
+	}
+	w.status = code
+	if !w.taggable() {
+		w.start() // nolint
+	}
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if !w.started && w.taggable() && len(w.buf)+len(p) <= w.maxSize {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// taggable tells whether the response so far can still get an ETag
+func (w *etagWriter) taggable() bool {
+	return w.status == http.StatusOK && w.Header().Get("Content-Disposition") == ""
+}
+
+// start sends the headers and the buffered bytes untagged, the rest is
+// written through
+func (w *etagWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// Flush gives up on the ETag, a streamed response is sent as it comes
+func (w *etagWriter) Flush() {
+	w.start() // nolint
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// etagMatch applies the weak comparison of If-None-Match
+func etagMatch(ifNoneMatch, tag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
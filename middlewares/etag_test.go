@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestETag(t *testing.T) {
+	n := negroni.New(ETag(1 << 10))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`[{"id": 1}]`)) // nolint
+	})
+	do := func(method, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/db/public/t", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, first.Code)
+	tag := first.Header().Get("ETag")
+	require.Regexp(t, `^W/"[0-9a-f]{32}"$`, tag)
+	require.Equal(t, `[{"id": 1}]`, first.Body.String())
+
+	for _, inm := range []string{tag, `"other", ` + tag, `*`, tag[2:]} {
+		rec := do(http.MethodGet, inm)
+		require.Equal(t, http.StatusNotModified, rec.Code, inm)
+		require.Empty(t, rec.Body.String())
+		require.Equal(t, tag, rec.Header().Get("ETag"))
+	}
+
+	rec := do(http.MethodGet, `W/"stale"`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `[{"id": 1}]`, rec.Body.String())
+
+	rec = do(http.MethodPost, tag)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Empty(t, rec.Header().Get("ETag"))
+}
+
+func TestETagPassthrough(t *testing.T) {
+	var testCases = []struct {
+		description string
+		handler     http.HandlerFunc
+		body        string
+	}{
+		{"too large", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id": 1}]`)) // nolint
+			w.Write([]byte(`[{"id": 2}]`)) // nolint
+		}, `[{"id": 1}][{"id": 2}]`},
+		{"attachment", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Disposition", `attachment; filename="t.csv"`)
+			w.Write([]byte("id\n1\n")) // nolint
+		}, "id\n1\n"},
+		{"streamed", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[")) // nolint
+			w.(http.Flusher).Flush()
+			w.Write([]byte("]")) // nolint
+		}, "[]"},
+	}
+	for _, tc := range testCases {
+		t.Log(tc.description)
+		n := negroni.New(ETag(16))
+		n.UseHandlerFunc(tc.handler)
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/db/public/t", nil))
+		require.Equal(t, http.StatusOK, rec.Code, tc.description)
+		require.Empty(t, rec.Header().Get("ETag"), tc.description)
+		require.Equal(t, tc.body, rec.Body.String(), tc.description)
+	}
+}
@@ -0,0 +1,146 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/urfave/negroni/v3"
+)
+
+var gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// incompressible content types are served as is
+var incompressible = []string{
+	"image/", "video/", "audio/",
+	"application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+	"application/octet-stream", "font/woff",
+}
+
+// Gzip compresses responses of at least minLength bytes for clients that
+// accept gzip. Only the first minLength bytes are buffered to take the
+// decision, the rest is streamed through the compressor
+func Gzip(minLength int) negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if r.Method == http.MethodHead || !acceptsGzip(r) || isUpgrade(r) {
+			next(rw, r)
+			return
+		}
+		rw.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipWriter{ResponseWriter: rw, minLength: minLength, status: http.StatusOK}
+		defer gw.Close()
+		next(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(enc), "gzip") && strings.ReplaceAll(q, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+type gzipWriter struct {
+	http.ResponseWriter
+	minLength int
+	status    int
+	buf       []byte
+	started   bool
+	gz        *gzip.Writer
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if !w.started {
+		w.status = code
+	}
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if w.started {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minLength {
+		if err := w.start(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start sends the headers and the buffered bytes, compressing from now on
+// when compress is set
+func (w *gzipWriter) start(compress bool) error {
+	w.started = true
+	h := w.Header()
+	if compress {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz = gzipPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *gzipWriter) compressible() bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	for _, prefix := range incompressible {
+		if strings.HasPrefix(ct, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush sends what was written so far, compressing it if allowed
+func (w *gzipWriter) Flush() {
+	if !w.started {
+		w.start(w.compressible()) // nolint
+	}
+	if w.gz != nil {
+		w.gz.Flush() // nolint
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes out short responses uncompressed and terminates the gzip stream
+func (w *gzipWriter) Close() error {
+	if !w.started {
+		return w.start(false)
+	}
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	gzipPool.Put(w.gz)
+	w.gz = nil
+	return err
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat(`{"id": 1}`, 200)
+
+	serve := func(body, contentType, acceptEncoding string) *httptest.ResponseRecorder {
+		n := negroni.New(Gzip(1024))
+		n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusCreated)
+			for i := 0; i < len(body); i += 100 {
+				w.Write([]byte(body[i:min(i+100, len(body))])) // nolint
+			}
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("large json is compressed", func(t *testing.T) {
+		rec := serve(large, "application/json", "br, gzip")
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		zr, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, large, string(b))
+	})
+
+	t.Run("small body is not compressed", func(t *testing.T) {
+		rec := serve(`{"id": 1}`, "application/json", "gzip")
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.Empty(t, rec.Header().Get("Content-Encoding"))
+		require.Equal(t, `{"id": 1}`, rec.Body.String())
+	})
+
+	t.Run("incompressible type", func(t *testing.T) {
+		rec := serve(large, "image/png", "gzip")
+		require.Empty(t, rec.Header().Get("Content-Encoding"))
+		require.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("client does not accept gzip", func(t *testing.T) {
+		rec := serve(large, "application/json", "gzip;q=0, deflate")
+		require.Empty(t, rec.Header().Get("Content-Encoding"))
+		require.Equal(t, large, rec.Body.String())
+	})
+}
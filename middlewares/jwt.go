@@ -0,0 +1,118 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/urfave/negroni/v3"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// TokenVerifier checks a bearer token and returns its claims, an error
+// rejects the request with 401
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (auth.Claims, error)
+}
+
+// Verifier replaces the JWT verifier of the default middleware stack, set it
+// before GetApp to plug in another identity provider
+var Verifier TokenVerifier
+
+// JWTVerifier verifies JWTs signed with Key or, when set, with the key of the
+// JWK set matching the token key ID. Issuer and Audience are checked when set
+type JWTVerifier struct {
+	Key      string
+	JWKSet   string
+	Issuer   string
+	Audience []string
+}
+
+// Verify implements TokenVerifier
+func (v *JWTVerifier) Verify(_ context.Context, token string) (claims auth.Claims, err error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return claims, ErrJWTParseFail
+	}
+	var rawkey interface{} = []byte(v.Key)
+
+	if v.JWKSet != "" {
+		parsedJWKSet, err := jwk.ParseString(v.JWKSet)
+		if err != nil {
+			slog.Error("failed to parse JWKSet JSON string", "err", err)
+			return claims, ErrJWKSetParse
+		}
+		for it := parsedJWKSet.Keys(context.Background()); it.Next(context.Background()); {
+			pair := it.Pair()
+			key := pair.Value.(jwk.Key)
+
+			if key.KeyID() == tok.Headers[0].KeyID {
+				if err := key.Raw(&rawkey); err != nil {
+					slog.Error("failed to create public key", "err", err)
+					return claims, ErrJWKSetCreate
+				}
+			}
+		}
+		//Check if rawkey is empty
+		if key, ok := rawkey.(string); ok {
+			if key == "" {
+				slog.Error("the token's key was not found in the JWKS")
+				return claims, ErrJWKSetKeyNotFound
+			}
+		}
+	}
+
+	if err := tok.Claims(rawkey, &claims); err != nil {
+		return claims, ErrJWTValidate
+	}
+	if err := Validate(claims); err != nil {
+		return claims, err
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return claims, ErrJWTValidate
+	}
+	if len(v.Audience) > 0 && !slices.ContainsFunc(v.Audience, claims.Audience.Contains) {
+		return claims, ErrJWTValidate
+	}
+	return claims, nil
+}
+
+// VerifyToken rejects the requests without a bearer token accepted by
+// verifier, apart from the JWT white list and those authenticated by APIKey,
+// and passes the claims of the token on in the request context
+func VerifyToken(verifier TokenVerifier) negroni.Handler {
+	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		match, err := MatchURL(r.URL.String())
+		if err != nil {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		if match || apiKeyAuthenticated(r) {
+			next(w, r)
+			return
+		}
+
+		// extract authorization token
+		token := strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
+		if token == "" {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrAuthIsEmpty.Error()), http.StatusUnauthorized)
+			return
+		}
+		claims, err := verifier.Verify(r.Context(), token)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), pctx.ClaimsKey, claims)
+		ctx = withUserInfo(ctx, claims.UserInfo)
+		next(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func signedToken(t *testing.T, key string, cl auth.Claims) string {
+	sig, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.HS256, Key: []byte(key)},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+	token, err := jwt.Signed(sig).Claims(cl).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWTVerifier(t *testing.T) {
+	verifier := &JWTVerifier{Key: "s3cr3t", Issuer: "https://idp.example.com", Audience: []string{"prest"}}
+	valid := auth.Claims{
+		UserInfo:  auth.User{Username: "gopher"},
+		Issuer:    "https://idp.example.com",
+		Audience:  jwt.Audience{"other", "prest"},
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		Expiry:    jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+
+	var testCases = []struct {
+		description string
+		key         string
+		claims      func(auth.Claims) auth.Claims
+		err         error
+	}{
+		{"valid token", "s3cr3t", func(c auth.Claims) auth.Claims { return c }, nil},
+		{"wrong key", "other", func(c auth.Claims) auth.Claims { return c }, ErrJWTValidate},
+		{"expired", "s3cr3t", func(c auth.Claims) auth.Claims {
+			c.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Second))
+			return c
+		}, ErrJWTValidate},
+		{"wrong issuer", "s3cr3t", func(c auth.Claims) auth.Claims {
+			c.Issuer = "https://evil.example.com"
+			return c
+		}, ErrJWTValidate},
+		{"wrong audience", "s3cr3t", func(c auth.Claims) auth.Claims {
+			c.Audience = jwt.Audience{"other"}
+			return c
+		}, ErrJWTValidate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), signedToken(t, tc.key, tc.claims(valid)))
+			require.Equal(t, tc.err, err)
+			if tc.err == nil {
+				require.Equal(t, "gopher", claims.UserInfo.Username)
+			}
+		})
+	}
+
+	_, err := verifier.Verify(context.Background(), "not a token")
+	require.Equal(t, ErrJWTParseFail, err)
+}
+
+type staticVerifier map[string]auth.Claims
+
+func (v staticVerifier) Verify(_ context.Context, token string) (auth.Claims, error) {
+	claims, ok := v[token]
+	if !ok {
+		return claims, ErrJWTValidate
+	}
+	return claims, nil
+}
+
+func TestVerifyToken(t *testing.T) {
+	verifier := staticVerifier{"good": {Subject: "42", UserInfo: auth.User{Username: "gopher"}}}
+	n := negroni.New(VerifyToken(verifier))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value(pctx.ClaimsKey).(auth.Claims)
+		user := r.Context().Value(pctx.UserInfoKey).(auth.User)
+		w.Write([]byte(claims.Subject + " " + user.Username))
+	})
+
+	for token, status := range map[string]int{
+		"":            http.StatusUnauthorized,
+		"Bearer bad":  http.StatusUnauthorized,
+		"Bearer good": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/prest-test/public/test", nil)
+		req.Header.Set("Authorization", token)
+		n.ServeHTTP(rec, req)
+		require.Equal(t, status, rec.Code, token)
+		if status == http.StatusOK {
+			require.Equal(t, "42 gopher", rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	pctx "github.com/prest/prest/v2/context"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/negroni/v3"
+)
+
+// unmatchedRoute labels requests that did not reach a registered route,
+// keeping raw paths out of the metric labels
+const unmatchedRoute = "unmatched"
+
+var (
+	// MetricsRegistry holds the pREST collectors served by MetricsHandler
+	MetricsRegistry = prometheus.NewRegistry()
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "prest_http_requests_total",
+		Help: "Number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "prest_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	MetricsRegistry.MustRegister(
+		requestsTotal,
+		requestDuration,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// MetricsHandler serves the collectors of MetricsRegistry
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(MetricsRegistry, promhttp.HandlerOpts{})
+}
+
+// Metrics records the duration and status code of each request
+//
+// The route label is the mux path template set by RouteLabel, so it must run
+// before the router
+func Metrics() negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		start := time.Now()
+		route := unmatchedRoute
+		ctx := context.WithValue(r.Context(), pctx.RouteKey, &route)
+		nrw := negroni.NewResponseWriter(rw)
+		next(nrw, r.WithContext(ctx))
+
+		requestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(nrw.Status())).Inc()
+		requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RouteLabel is a mux middleware reporting the matched path template to
+// Metrics, nested routers overwrite the label of their parent
+func RouteLabel(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(pctx.RouteKey).(*string); ok {
+			if cur := mux.CurrentRoute(r); cur != nil {
+				if tpl, err := cur.GetPathTemplate(); err == nil {
+					*route = tpl
+				}
+			}
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// RegisterDBMetrics exposes the connection pool stats returned by stats as gauges
+func RegisterDBMetrics(stats func() sql.DBStats) {
+	gauge := func(name, help string, value func(sql.DBStats) int) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(stats()))
+		})
+	}
+	MetricsRegistry.MustRegister(
+		gauge("prest_db_open_connections", "Established database connections, in use and idle.",
+			func(s sql.DBStats) int { return s.OpenConnections }),
+		gauge("prest_db_in_use_connections", "Database connections currently in use.",
+			func(s sql.DBStats) int { return s.InUse }),
+		gauge("prest_db_idle_connections", "Idle database connections.",
+			func(s sql.DBStats) int { return s.Idle }),
+		gauge("prest_db_max_open_connections", "Maximum number of open database connections.",
+			func(s sql.DBStats) int { return s.MaxOpenConnections }),
+	)
+}
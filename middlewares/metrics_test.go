@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestMetrics(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(RouteLabel)
+	r.HandleFunc("/{database}/{schema}/{table}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	n := negroni.New(Metrics())
+	n.UseHandler(r)
+
+	for _, path := range []string{"/db/public/a", "/db/public/b", "/nope"} {
+		n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+	}
+
+	require.Equal(t, 2.0, testutil.ToFloat64(requestsTotal.WithLabelValues("POST", "/{database}/{schema}/{table}", "201")))
+	require.Equal(t, 1.0, testutil.ToFloat64(requestsTotal.WithLabelValues("POST", unmatchedRoute, "404")))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	RegisterDBMetrics(func() sql.DBStats { return sql.DBStats{OpenConnections: 3, InUse: 1, Idle: 2} })
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(body), "prest_db_open_connections 3"))
+	require.True(t, strings.Contains(string(body), "prest_db_idle_connections 2"))
+}
@@ -15,7 +15,6 @@ import (
 	pctx "github.com/prest/prest/v2/context"
 	"github.com/prest/prest/v2/controllers/auth"
 
-	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/urfave/negroni/v3"
 	jose "gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
@@ -35,11 +34,21 @@ var (
 // HandlerSet add content type header
 func HandlerSet() negroni.Handler {
 	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		format := r.URL.Query().Get("_renderer")
+		if isUpgrade(r) {
+			next(w, r)
+			return
+		}
+		format := responseFormat(r)
+		if format == "csv" {
+			cw := newCSVResponseWriter(w, csvDelimiter(config.PrestConf.CSVDelimiter), pctx.RequestIDFromContext(r.Context()))
+			next(negroni.NewResponseWriter(cw), r)
+			cw.close()
+			return
+		}
 		recorder := httptest.NewRecorder()
 		negroniResp := negroni.NewResponseWriter(recorder)
 		next(negroniResp, r)
-		renderFormat(w, recorder, format)
+		renderFormat(w, recorder, format, pctx.RequestIDFromContext(r.Context()))
 	})
 }
 
@@ -52,6 +61,55 @@ func SetTimeoutToContext() negroni.Handler {
 	})
 }
 
+// UsePrimary sends the reads of requests with _primary=true to the primary
+// database instead of a read replica, for read-after-write consistency
+func UsePrimary() negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if r.URL.Query().Get("_primary") == "true" {
+			r = r.WithContext(context.WithValue(r.Context(), pctx.UsePrimaryKey, true))
+		}
+		next(rw, r)
+	})
+}
+
+// ReadOnly rejects the writes, every method but GET, HEAD and OPTIONS, with
+// 503 while read-only mode is active. Authenticating stays possible
+func ReadOnly() negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if !config.IsReadOnly() {
+			next(rw, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next(rw, r)
+			return
+		}
+		if r.URL.Path == strings.TrimSuffix(config.PrestConf.ContextPath, "/")+"/auth" {
+			next(rw, r)
+			return
+		}
+		http.Error(rw, fmt.Sprintf(jsonErrFormat, "read-only mode, writes are disabled"), http.StatusServiceUnavailable)
+	})
+}
+
+// RequestTimeout bounds each request to the configured RequestTimeout in
+// seconds, 0 disables it. The request context is canceled at the deadline,
+// which aborts running queries, and the handlers answer a 503. Responses
+// are still written straight to rw so they can stream
+func RequestTimeout() negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if config.PrestConf.RequestTimeout <= 0 || isUpgrade(r) {
+			next(rw, r)
+			return
+		}
+		timeout := time.Duration(config.PrestConf.RequestTimeout) * time.Second
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next(rw, r.WithContext(ctx))
+	})
+}
+
 // AuthMiddleware handle request token validation
 func AuthMiddleware(_ string) negroni.Handler {
 	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
@@ -60,7 +118,7 @@ func AuthMiddleware(_ string) negroni.Handler {
 			http.Error(rw, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusInternalServerError)
 			return
 		}
-		if config.PrestConf.AuthEnabled && !match {
+		if config.PrestConf.AuthEnabled && !match && !apiKeyAuthenticated(r) {
 			// extract authorization token
 			token := strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
 			if token == "" {
@@ -86,7 +144,7 @@ func AuthMiddleware(_ string) negroni.Handler {
 
 			// pass user_info to the next handler
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, pctx.UserInfoKey, claims.UserInfo)
+			ctx = withUserInfo(ctx, claims.UserInfo)
 			r = r.WithContext(ctx)
 		}
 
@@ -142,69 +200,11 @@ func AccessControl() negroni.Handler {
 
 // JwtMiddleware check if actual request have JWT
 func JwtMiddleware(key string, JWKSet, _ string) negroni.Handler {
-	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		match, err := MatchURL(r.URL.String())
-		if err != nil {
-			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusInternalServerError)
-			return
-		}
-		if match {
-			next(w, r)
-			return
-		}
-
-		// extract authorization token
-		token := strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
-		if token == "" {
-			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrAuthIsEmpty.Error()), http.StatusUnauthorized)
-			return
-		}
-		tok, err := jwt.ParseSigned(token)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWTParseFail.Error()), http.StatusUnauthorized)
-			return
-		}
-		out := auth.Claims{}
-		var rawkey interface{} = []byte(key)
-
-		if JWKSet != "" {
-			parsedJWKSet, err := jwk.ParseString(JWKSet)
-			if err != nil {
-				slog.Error("failed to parse JWKSet JSON string", "err", err)
-				http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWKSetParse.Error()), http.StatusUnauthorized)
-				return
-			}
-			for it := parsedJWKSet.Keys(context.Background()); it.Next(context.Background()); {
-				pair := it.Pair()
-				key := pair.Value.(jwk.Key)
-
-				if key.KeyID() == tok.Headers[0].KeyID {
-					if err := key.Raw(&rawkey); err != nil {
-						slog.Error("failed to create public key", "err", err)
-						http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWKSetCreate.Error()), http.StatusUnauthorized)
-						return
-					}
-				}
-			}
-			//Check if rawkey is empty
-			if key, ok := rawkey.(string); ok {
-				if key == "" {
-					slog.Error("the token's key was not found in the JWKS")
-					http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWKSetKeyNotFound.Error()), http.StatusUnauthorized)
-					return
-				}
-			}
-		}
-
-		if err := tok.Claims(rawkey, &out); err != nil {
-			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWTValidate.Error()), http.StatusUnauthorized)
-			return
-		}
-		if err := Validate(out); err != nil {
-			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusUnauthorized)
-			return
-		}
-		next(w, r)
+	return VerifyToken(&JWTVerifier{
+		Key:      key,
+		JWKSet:   JWKSet,
+		Issuer:   config.PrestConf.JWTIssuer,
+		Audience: config.PrestConf.JWTAudience,
 	})
 }
 
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
@@ -10,10 +11,12 @@ import (
 	"time"
 
 	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
 	"github.com/prest/prest/v2/controllers/auth"
 
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
 	jose "gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
@@ -252,3 +255,89 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestTimeout(t *testing.T) {
+	defer func(cfg *config.Prest) { config.PrestConf = cfg }(config.PrestConf)
+	var ctxErr error
+	slow := negroni.New(RequestTimeout())
+	slow.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		ctxErr = r.Context().Err()
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		config.PrestConf = &config.Prest{}
+		h := negroni.New(RequestTimeout())
+		h.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, ok := r.Context().Deadline()
+			require.False(t, ok)
+		})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		config.PrestConf = &config.Prest{RequestTimeout: 1}
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		slow.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Less(t, time.Since(start), 3*time.Second)
+		require.ErrorIs(t, ctxErr, context.DeadlineExceeded)
+		require.True(t, rec.Flushed, "the response is written straight through")
+		require.Equal(t, "partial", rec.Body.String())
+	})
+}
+
+func TestUsePrimary(t *testing.T) {
+	for _, tc := range []struct {
+		url  string
+		want bool
+	}{
+		{"/", false},
+		{"/?_primary=false", false},
+		{"/?_primary=true", true},
+	} {
+		h := negroni.New(UsePrimary())
+		h.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			primary, _ := r.Context().Value(pctx.UsePrimaryKey).(bool)
+			require.Equal(t, tc.want, primary, tc.url)
+		})
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.url, nil))
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	defer func(cfg *config.Prest) { config.PrestConf = cfg }(config.PrestConf)
+	defer config.SetReadOnly(false)
+	config.PrestConf = &config.Prest{ContextPath: "/"}
+
+	h := negroni.New(ReadOnly())
+	h.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, tc := range []struct {
+		readOnly bool
+		method   string
+		path     string
+		status   int
+	}{
+		{false, http.MethodPost, "/prest/public/test", http.StatusOK},
+		{true, http.MethodGet, "/prest/public/test", http.StatusOK},
+		{true, http.MethodPost, "/prest/public/test", http.StatusServiceUnavailable},
+		{true, http.MethodPatch, "/prest/public/test", http.StatusServiceUnavailable},
+		{true, http.MethodDelete, "/prest/public/test", http.StatusServiceUnavailable},
+		{true, http.MethodPost, "/batch", http.StatusServiceUnavailable},
+		{true, http.MethodPost, "/prest/public/auth", http.StatusServiceUnavailable},
+		{true, http.MethodPost, "/auth", http.StatusOK},
+	} {
+		config.SetReadOnly(tc.readOnly)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		require.Equal(t, tc.status, rec.Code, "%v %s %s", tc.readOnly, tc.method, tc.path)
+	}
+}
@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prest/prest/v2/config"
+
+	"github.com/urfave/negroni/v3"
+	"golang.org/x/time/rate"
+)
+
+// visitorTTL is how long an idle client keeps its bucket
+const visitorTTL = 3 * time.Minute
+
+// RateLimitKey extracts the key requests are throttled by
+type RateLimitKey func(r *http.Request) string
+
+// ClientIP keys requests by the remote address of the connection
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HeaderKey keys requests by the given header, falling back to the client
+// IP when it is missing. The value is trusted as is, so it suits headers set
+// by a trusted proxy; use APIKeyName for X-API-Key
+func HeaderKey(name string) RateLimitKey {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return ClientIP(r)
+	}
+}
+
+// APIKeyName keys requests by the name of their X-API-Key among keys, so
+// rotating made-up keys doesn't get a fresh bucket each time: unknown keys
+// and requests without one are keyed by the client IP
+func APIKeyName(keys []config.APIKeyConf) RateLimitKey {
+	byHash := apiKeysByHash(keys)
+	return func(r *http.Request) string {
+		if k, ok := byHash.lookup(r.Header.Get(apiKeyHeader)); ok {
+			return apiKeyHeader + ":" + k.Name
+		}
+		return ClientIP(r)
+	}
+}
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	visitors  map[string]*visitor
+	lastSweep time.Time
+	rps       rate.Limit
+	burst     int
+}
+
+func (l *rateLimiter) get(key string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > visitorTTL {
+		for k, v := range l.visitors {
+			if now.Sub(v.lastSeen) > visitorTTL {
+				delete(l.visitors, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	v, ok := l.visitors[key]
+	if !ok {
+		v = &visitor{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.visitors[key] = v
+	}
+	v.lastSeen = now
+	return v.limiter
+}
+
+// RateLimit throttles each key to rps requests per second with bursts of
+// up to burst requests, answering 429 with a Retry-After header beyond that
+func RateLimit(rps float64, burst int, key RateLimitKey) negroni.Handler {
+	l := &rateLimiter{
+		visitors: make(map[string]*visitor),
+		rps:      rate.Limit(rps),
+		burst:    burst,
+	}
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		now := time.Now()
+		res := l.get(key(r), now).ReserveN(now, 1)
+		if delay := res.DelayFrom(now); !res.OK() || delay > 0 {
+			res.CancelAt(now)
+			retry := 1
+			if res.OK() {
+				retry = int(math.Ceil(delay.Seconds()))
+			}
+			rw.Header().Set("Retry-After", strconv.Itoa(retry))
+			http.Error(rw, fmt.Sprintf(jsonErrFormat, "rate limit exceeded"), http.StatusTooManyRequests)
+			return
+		}
+		next(rw, r)
+	})
+}
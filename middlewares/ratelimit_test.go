@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prest/prest/v2/config"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni/v3"
+)
+
+func TestRateLimit(t *testing.T) {
+	n := negroni.New(RateLimit(1, 2, ClientIP))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	do := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("10.0.0.1:1000").Code)
+	require.Equal(t, http.StatusOK, do("10.0.0.1:1001").Code)
+	rec := do("10.0.0.1:1002")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// other clients have their own bucket
+	require.Equal(t, http.StatusOK, do("10.0.0.2:1000").Code)
+}
+
+func TestHeaderKey(t *testing.T) {
+	key := HeaderKey("X-API-Key")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1000"
+	require.Equal(t, "10.0.0.1", key(req))
+
+	req.Header.Set("X-API-Key", "k1")
+	require.Equal(t, "X-API-Key:k1", key(req))
+}
+
+func TestAPIKeyNameRotatingKeys(t *testing.T) {
+	n := negroni.New(RateLimit(1, 2, APIKeyName([]config.APIKeyConf{
+		{Name: "billing", Hash: apiKeyHash("real-key"), Role: "billing"},
+	})))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	do := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("made-up-1"))
+	require.Equal(t, http.StatusOK, do("made-up-2"))
+	require.Equal(t, http.StatusTooManyRequests, do("made-up-3"), "unknown keys share the client IP bucket")
+	for i := 4; i < 8; i++ {
+		require.Equal(t, http.StatusTooManyRequests, do(fmt.Sprint("made-up-", i)))
+	}
+
+	// a configured key has its own bucket
+	require.Equal(t, http.StatusOK, do("real-key"))
+	require.Equal(t, http.StatusOK, do("real-key"))
+	require.Equal(t, http.StatusTooManyRequests, do("real-key"))
+}
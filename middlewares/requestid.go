@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	pctx "github.com/prest/prest/v2/context"
+)
+
+// RequestIDHeader carries the request id in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID takes the id of the request from RequestIDHeader or generates
+// a UUID, stores it in the context and echoes it in the response
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newUUID()
+		}
+		rw.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), pctx.RequestIDKey, id)))
+	})
+}
+
+// validRequestID accepts short printable ASCII ids so they can be logged as is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:]) // nolint: never fails, see crypto/rand
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	pctx "github.com/prest/prest/v2/context"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = pctx.RequestIDFromContext(r.Context())
+	}))
+
+	for _, tc := range []struct {
+		name, header string
+		keep         bool
+	}{
+		{"incoming id", "req-42", true},
+		{"missing id", "", false},
+		{"unprintable id", "bad\nid", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tc.header)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, got, rec.Header().Get(RequestIDHeader))
+			if tc.keep {
+				require.Equal(t, tc.header, got)
+				return
+			}
+			require.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got)
+		})
+	}
+}
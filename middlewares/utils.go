@@ -57,7 +57,13 @@ func permissionByMethod(method string) (permission string) {
 	return
 }
 
-func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, format string) {
+// isUpgrade tells whether r asks to switch protocols, e.g. to a websocket,
+// such responses must reach the connection and can't be buffered
+func isUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != ""
+}
+
+func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, format, requestID string) {
 	for key := range recorder.Header() {
 		w.Header().Set(key, recorder.Header().Get(key))
 	}
@@ -65,6 +71,9 @@ func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, fo
 	if recorder.Code >= 400 {
 		m := make(map[string]string)
 		m["error"] = strings.TrimSpace(string(byt))
+		if requestID != "" {
+			m["request_id"] = requestID
+		}
 		byt, _ = json.MarshalIndent(m, "", "\t")
 	}
 	switch format {
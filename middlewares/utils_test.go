@@ -2,10 +2,13 @@ package middlewares
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/prest/prest/v2/config"
 	"github.com/prest/prest/v2/middlewares/statements"
+
+	"github.com/stretchr/testify/require"
 )
 
 func Test_getVars(t *testing.T) {
@@ -111,3 +114,20 @@ func TestMatchURL(t *testing.T) {
 		})
 	}
 }
+
+func Test_renderFormatRequestID(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	http.Error(recorder, "table not found", http.StatusNotFound)
+
+	rec := httptest.NewRecorder()
+	renderFormat(rec, recorder, "", "req-42")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.JSONEq(t, `{"error": "table not found", "request_id": "req-42"}`, rec.Body.String())
+}
+
+func Test_isUpgrade(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/_REALTIME", nil)
+	require.False(t, isUpgrade(r))
+	r.Header.Set("Upgrade", "websocket")
+	require.True(t, isUpgrade(r))
+}
@@ -16,6 +16,7 @@ import (
 // v2: this is not used anywhere, so we can make it private
 func GetRouter() *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
+	router.Use(middlewares.RouteLabel)
 
 	if config.PrestConf.AuthEnabled {
 		// can be db specific in the future, there's bellow a proposal
@@ -36,12 +37,20 @@ func GetRouter() *mux.Router {
 	if runtime.GOOS != "windows" {
 		router.HandleFunc("/_PLUGIN/{file}/{func}", plugins.HandlerPlugin)
 	}
+	if config.PrestConf.OpenAPIEnabled {
+		router.HandleFunc("/_openapi.json", controllers.OpenAPI).Methods("GET")
+	}
+	if config.PrestConf.RealtimeEnabled {
+		router.HandleFunc("/_REALTIME", controllers.Realtime).Methods("GET")
+	}
 	router.HandleFunc("/{database}/{schema}", controllers.GetTablesByDatabaseAndSchema).Methods("GET")
 	router.HandleFunc("/show/{database}/{schema}/{table}", controllers.ShowTable).Methods("GET")
 	crudRoutes := mux.NewRouter().PathPrefix("/").Subrouter().StrictSlash(true)
+	crudRoutes.Use(middlewares.RouteLabel)
 	router.HandleFunc("/_health", controllers.WrappedHealthCheck(controllers.DefaultCheckList)).Methods("GET")
 	crudRoutes.HandleFunc("/{database}/{schema}/{table}", controllers.SelectFromTables).Methods("GET")
 	crudRoutes.HandleFunc("/{database}/{schema}/{table}", controllers.InsertInTables).Methods("POST")
+	crudRoutes.HandleFunc("/batch", controllers.Batch).Methods("POST")
 	crudRoutes.HandleFunc("/batch/{database}/{schema}/{table}", controllers.BatchInsertInTables).Methods("POST")
 	crudRoutes.HandleFunc("/{database}/{schema}/{table}", controllers.DeleteFromTable).Methods("DELETE")
 	crudRoutes.HandleFunc("/{database}/{schema}/{table}", controllers.UpdateTable).Methods("PUT", "PATCH")
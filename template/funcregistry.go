@@ -3,50 +3,120 @@ package template
 import (
 	"fmt"
 	"net/url"
+	"os"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/prest/prest/v2/internal/ident"
+
+	"github.com/lib/pq"
 )
 
 // FuncRegistry registry func for templates
+//
+// The SQL helpers append bound values to Args while the template executes,
+// so a registry must be freshly constructed or Reset before each render
 type FuncRegistry struct {
 	TemplateData map[string]interface{}
 	Args         []interface{}
+	// EnvAllowList are the environment variables the env helper may read
+	EnvAllowList []string
 	next         int
+	// named maps keys bound with sqlValNamed to their placeholder index
+	named map[string]int
+}
+
+// Reset clears the bound arguments and placeholder counter so the registry
+// can render another template
+func (fr *FuncRegistry) Reset() {
+	fr.Args = nil
+	fr.next = 0
+	fr.named = nil
+}
+
+// BoundArgs returns a copy of the arguments bound by the last render, in
+// placeholder order
+func (fr *FuncRegistry) BoundArgs() []interface{} {
+	return append([]interface{}(nil), fr.Args...)
 }
 
 // RegistryAllFuncs for template
 func (fr *FuncRegistry) RegistryAllFuncs() (funcs template.FuncMap) {
 	funcs = template.FuncMap{
-		"isSet":          fr.isSet,
-		"defaultOrValue": fr.defaultOrValue,
-		"inFormat":       fr.inFormat,
-		"unEscape":       fr.unEscape,
-		"split":          fr.split,
-		"limitOffset":    fr.limitOffset,
+		"isSet":           fr.isSet,
+		"defaultOrValue":  fr.defaultOrValue,
+		"inFormat":        fr.inFormat,
+		"unEscape":        fr.unEscape,
+		"split":           fr.split,
+		"limitOffset":     fr.limitOffset,
+		"limitOffsetArgs": fr.limitOffsetArgs,
+		// string helpers, they transform values and must not be written into SQL directly
+		"upper":   fr.upper,
+		"lower":   fr.lower,
+		"trim":    fr.trim,
+		"replace": fr.replace,
+		"fmtTime": fr.fmtTime,
 		// secure SQL helpers
-		"sqlVal":  fr.sqlVal,
-		"sqlList": fr.sqlList,
-		"ident":   fr.ident,
+		"sqlVal":      fr.sqlVal,
+		"sqlList":     fr.sqlList,
+		"sqlValNamed": fr.sqlValNamed,
+		"sqlArray":    fr.sqlArray,
+		"sqlValCast":  fr.sqlValCast,
+		"sqlBetween":  fr.sqlBetween,
+		"boolVal":     fr.boolVal,
+		"intVal":      fr.intVal,
+		"sqlIn":       fr.sqlIn,
+		"sqlLike":     fr.sqlLike,
+		"orderBy":     fr.orderBy,
+		"keyset":      fr.keyset,
+		"coalesce":    fr.coalesce,
+		"env":         fr.env,
+		"ident":       fr.ident,
 	}
 	return
 }
 
 func (fr *FuncRegistry) isSet(key string) (ok bool) {
-	_, ok = fr.TemplateData[key]
+	_, ok = fr.lookup(key)
 	return
 }
 
+// defaultOrValue returns the value at key, or defaultValue when it is not set.
+// TemplateData is left untouched so isSet keeps reporting what the user sent
 func (fr *FuncRegistry) defaultOrValue(key, defaultValue string) (value interface{}) {
-	if ok := fr.isSet(key); !ok {
-		fr.TemplateData[key] = defaultValue
+	value, ok := fr.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	return
+}
+
+// lookup returns the value at key, a dotted key such as "filter.status" is
+// looked up in the nested maps of TemplateData when it is not a key itself
+func (fr *FuncRegistry) lookup(key string) (value interface{}, ok bool) {
+	if value, ok = fr.TemplateData[key]; ok || !strings.Contains(key, ".") {
+		return
+	}
+	m := fr.TemplateData
+	path := strings.Split(key, ".")
+	for _, k := range path[:len(path)-1] {
+		if m, ok = m[k].(map[string]interface{}); !ok {
+			return nil, false
+		}
 	}
-	value = fr.TemplateData[key]
+	value, ok = m[path[len(path)-1]]
 	return
 }
 
+// inFormat builds an IN list by quoting the raw values into the query.
+//
+// Deprecated: inFormat is vulnerable to SQL injection, a value containing
+// `')` breaks out of the list. Replace {{inFormat "key"}} with {{sqlIn "key"}}
+// in existing templates.
 func (fr *FuncRegistry) inFormat(key string) (query string) {
 	items, ok := fr.TemplateData[key].([]string)
 	if !ok {
@@ -62,58 +132,417 @@ func (fr *FuncRegistry) unEscape(key string) (value string) {
 	return
 }
 
-func (fr *FuncRegistry) split(orig, sep string) (values []string) {
-	values = strings.Split(orig, sep)
+// split splits orig around sep, an optional count limits the pieces as
+// strings.SplitN does, e.g. {{ split .path "/" 2 }}
+func (fr *FuncRegistry) split(orig, sep string, count ...int) (values []string, err error) {
+	switch len(count) {
+	case 0:
+		values = strings.Split(orig, sep)
+	case 1:
+		values = strings.SplitN(orig, sep, count[0])
+	default:
+		err = fmt.Errorf("split takes at most one count, got %d", len(count))
+	}
 	return
 }
 
-// LimitOffset create and format limit query (offset, SQL ANSI)
-func LimitOffset(pageNumberStr, pageSizeStr string) (paginatedQuery string, err error) {
-	pageNumber, err := strconv.Atoi(pageNumberStr)
+// env returns the environment variable name, or defaultValue when it is
+// unset. Only the variables of EnvAllowList can be read, any other name also
+// gets defaultValue, so a template can't read the secrets prestd itself is
+// configured with, such as PREST_PG_PASS or PREST_JWT_KEY. Like upper and
+// lower the result is a plain string for template logic such as
+// {{ if eq (env "REGION") "eu" }}, it must not be written into SQL directly
+func (fr *FuncRegistry) env(name string, defaultValue ...string) (string, error) {
+	if len(defaultValue) > 1 {
+		return "", fmt.Errorf("env takes at most one default, got %d", len(defaultValue))
+	}
+	def := strings.Join(defaultValue, "")
+	if !slices.Contains(fr.EnvAllowList, name) {
+		return def, nil
+	}
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	return def, nil
+}
+
+// MaxPageSize caps the page size accepted by LimitOffset and limitOffsetArgs,
+// larger sizes are clamped to it. Zero means no limit, it is set from
+// config.PrestConf.PaginationMaxPageSize when the app starts
+var MaxPageSize = 0
+
+// PageBounds parses and validates the page number and size, a page number
+// below 1 is moved to the first page and the size is clamped to MaxPageSize.
+// It is shared by the pagination helpers and the pagination headers
+func PageBounds(pageNumberStr, pageSizeStr string) (pageNumber, pageSize int, err error) {
+	pageNumber, err = strconv.Atoi(pageNumberStr)
 	if err != nil {
 		return
 	}
-	pageSize, err := strconv.Atoi(pageSizeStr)
+	pageSize, err = strconv.Atoi(pageSizeStr)
 	if err != nil {
 		return
 	}
+	if pageSize <= 0 {
+		err = fmt.Errorf("invalid page size %d, must be positive", pageSize)
+		return
+	}
+	if MaxPageSize > 0 && pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
 	if pageNumber-1 < 0 {
 		pageNumber = 1
 	}
-	paginatedQuery = fmt.Sprintf("LIMIT %d OFFSET(%d - 1) * %d", pageSize, pageNumber, pageSize)
 	return
 }
 
-func (fr *FuncRegistry) limitOffset(pageNumber, pageSize string) (value string) {
-	value, err := LimitOffset(pageNumber, pageSize)
+// upper, lower, trim and replace transform the value at key for use in
+// template logic such as {{ if eq (lower "status") "active" }}. They never
+// touch Args: the result is a plain string and must not be interpolated into
+// SQL, values that reach the query still have to be bound with sqlVal
+
+func (fr *FuncRegistry) upper(key string) string {
+	return strings.ToUpper(fr.str(key))
+}
+
+func (fr *FuncRegistry) lower(key string) string {
+	return strings.ToLower(fr.str(key))
+}
+
+func (fr *FuncRegistry) trim(key string) string {
+	return strings.TrimSpace(fr.str(key))
+}
+
+func (fr *FuncRegistry) replace(key, old, new string) string {
+	return strings.ReplaceAll(fr.str(key), old, new)
+}
+
+// fmtTime formats the time at key, epoch seconds or RFC 3339, with layout in
+// the IANA time zone tz, e.g. {{ fmtTime "created" "2006-01-02 15:04 MST" "Europe/Paris" }}.
+// Like upper it is meant for presentation and never touches Args
+func (fr *FuncRegistry) fmtTime(key, layout, tz string) (string, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return "", fmt.Errorf("invalid time zone %q: %v", tz, err)
+	}
+	var t time.Time
+	switch v := fr.TemplateData[key].(type) {
+	case time.Time:
+		t = v
+	case int:
+		t = time.Unix(int64(v), 0)
+	case int64:
+		t = time.Unix(v, 0)
+	case float64:
+		t = time.Unix(0, int64(v*float64(time.Second)))
+	default:
+		str := fr.str(key)
+		if sec, err := strconv.ParseInt(str, 10, 64); err == nil {
+			t = time.Unix(sec, 0)
+		} else if t, err = time.Parse(time.RFC3339, str); err != nil {
+			return "", fmt.Errorf("invalid time value for %q: %v", key, fr.TemplateData[key])
+		}
+	}
+	return t.In(loc).Format(layout), nil
+}
+
+// str returns the value at key as a string, empty when the key is not set
+func (fr *FuncRegistry) str(key string) string {
+	v, ok := fr.TemplateData[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// LimitOffset create and format limit query (offset, SQL ANSI)
+func LimitOffset(pageNumberStr, pageSizeStr string) (paginatedQuery string, err error) {
+	pageNumber, pageSize, err := PageBounds(pageNumberStr, pageSizeStr)
 	if err != nil {
-		value = ""
+		return
 	}
+	paginatedQuery = fmt.Sprintf("LIMIT %d OFFSET(%d - 1) * %d", pageSize, pageNumber, pageSize)
 	return
 }
 
+// limitOffset formats the page into the query as literals, kept for
+// compatibility; new templates should use limitOffsetArgs. An invalid page
+// fails the template rather than leaving the query unbounded
+func (fr *FuncRegistry) limitOffset(pageNumber, pageSize string) (string, error) {
+	return LimitOffset(pageNumber, pageSize)
+}
+
+// limitOffsetArgs binds page size and offset as parameters and returns
+// "LIMIT $n OFFSET $m", the offset is computed as (page - 1) * size.
+// Prefer it to limitOffset so prepared statement plans can be reused
+func (fr *FuncRegistry) limitOffsetArgs(pageNumberStr, pageSizeStr string) (string, error) {
+	pageNumber, pageSize, err := PageBounds(pageNumberStr, pageSizeStr)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("LIMIT %s OFFSET %s", fr.bind(pageSize), fr.bind((pageNumber-1)*pageSize)), nil
+}
+
 // sqlVal returns a positional placeholder for a single value and stores it in Args
 func (fr *FuncRegistry) sqlVal(key string) string {
-	v := fr.TemplateData[key]
+	return fr.bind(fr.TemplateData[key])
+}
+
+// castTypes are the PostgreSQL types accepted by sqlValCast, each can also be
+// used as an array with a [] suffix
+var castTypes = map[string]bool{
+	"smallint": true, "int": true, "integer": true, "bigint": true,
+	"int2": true, "int4": true, "int8": true,
+	"real": true, "float4": true, "float8": true, "double precision": true,
+	"numeric": true, "decimal": true,
+	"text": true, "varchar": true, "char": true,
+	"bool": true, "boolean": true,
+	"uuid": true,
+	"date": true, "time": true, "timetz": true, "timestamp": true, "timestamptz": true, "interval": true,
+	"json": true, "jsonb": true,
+	"bytea": true, "inet": true, "cidr": true,
+}
+
+// sqlValCast is like sqlVal but adds an explicit cast, e.g. $1::timestamptz.
+// pgType must be one of castTypes, anything else is rejected to keep arbitrary
+// text out of the query
+func (fr *FuncRegistry) sqlValCast(key, pgType string) (string, error) {
+	t := strings.ToLower(strings.TrimSpace(pgType))
+	if !castTypes[strings.TrimSuffix(t, "[]")] {
+		return "", fmt.Errorf("invalid cast type %q", pgType)
+	}
+	return fr.sqlVal(key) + "::" + t, nil
+}
+
+// sqlValNamed is like sqlVal but binds each key only once, further references
+// to the same key reuse its placeholder
+func (fr *FuncRegistry) sqlValNamed(key string) string {
+	if n, ok := fr.named[key]; ok {
+		return fmt.Sprintf("$%d", n)
+	}
+	ph := fr.bind(fr.TemplateData[key])
+	if fr.named == nil {
+		fr.named = make(map[string]int)
+	}
+	fr.named[key] = fr.next
+	return ph
+}
+
+// bind stores v in Args and returns its positional placeholder
+func (fr *FuncRegistry) bind(v interface{}) string {
 	fr.Args = append(fr.Args, v)
 	fr.next++
 	return fmt.Sprintf("$%d", fr.next)
 }
 
-// sqlList returns a parenthesized, comma-separated list of placeholders for a slice value
+// sqlList returns a parenthesized, comma-separated list of placeholders for a slice value,
+// any slice type is expanded to one placeholder per element ([]byte is kept as a single value)
 func (fr *FuncRegistry) sqlList(key string) string {
-	if s, ok := fr.TemplateData[key].([]string); ok {
-		ph := make([]string, len(s))
-		for i := range s {
-			fr.Args = append(fr.Args, s[i])
-			fr.next++
-			ph[i] = fmt.Sprintf("$%d", fr.next)
+	v := reflect.ValueOf(fr.TemplateData[key])
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return fmt.Sprintf("(%s)", fr.bind(fr.TemplateData[key]))
+	}
+	ph := make([]string, v.Len())
+	for i := range ph {
+		ph[i] = fr.bind(v.Index(i).Interface())
+	}
+	return fmt.Sprintf("(%s)", strings.Join(ph, ","))
+}
+
+// sqlArray binds a slice value as a single PostgreSQL array parameter, for
+// use as "= ANY($1)". It is Postgres specific and, unlike sqlList, uses one
+// placeholder whatever the number of elements. A single string value is bound
+// as a one element array
+func (fr *FuncRegistry) sqlArray(key string) (string, error) {
+	v := fr.TemplateData[key]
+	if s, ok := v.(string); ok {
+		v = []string{s}
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Slice {
+		return "", fmt.Errorf("invalid array value for %q: %v", key, v)
+	}
+	return fr.bind(pq.Array(v)), nil
+}
+
+// sqlBetween binds a [low, high] pair and returns "BETWEEN $n AND $m"
+func (fr *FuncRegistry) sqlBetween(key string) (string, error) {
+	v := reflect.ValueOf(fr.TemplateData[key])
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != 2 {
+		return "", fmt.Errorf("invalid between value for %q, expected [low, high]: %v", key, fr.TemplateData[key])
+	}
+	low := fr.bind(v.Index(0).Interface())
+	return fmt.Sprintf("BETWEEN %s AND %s", low, fr.bind(v.Index(1).Interface())), nil
+}
+
+// boolVal parses the value at key as true/false/1/0 and binds it as a boolean
+func (fr *FuncRegistry) boolVal(key string) (string, error) {
+	switch strings.ToLower(fmt.Sprint(fr.TemplateData[key])) {
+	case "true", "1":
+		return fr.bind(true), nil
+	case "false", "0":
+		return fr.bind(false), nil
+	}
+	return "", fmt.Errorf("invalid boolean value for %q: %v", key, fr.TemplateData[key])
+}
+
+// intVal parses the value at key as a base 10 integer and binds it
+func (fr *FuncRegistry) intVal(key string) (string, error) {
+	i, err := strconv.ParseInt(fmt.Sprint(fr.TemplateData[key]), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid integer value for %q: %v", key, fr.TemplateData[key])
+	}
+	return fr.bind(i), nil
+}
+
+// sqlIn is the parameterized replacement for inFormat, it returns an IN list
+// such as ($1,$2,$3) for a []string value or ($1) for a single value
+func (fr *FuncRegistry) sqlIn(key string) string {
+	return fr.sqlList(key)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// sqlLike binds the value as a LIKE pattern and returns "$n ESCAPE '\'" to be used after LIKE/ILIKE.
+// Wildcards in the value are escaped; position sets where the pattern matches:
+// "prefix" (value%), "suffix" (%value) or "contains" (%value%)
+func (fr *FuncRegistry) sqlLike(key, position string) (string, error) {
+	value := likeEscaper.Replace(fmt.Sprint(fr.TemplateData[key]))
+	switch position {
+	case "prefix":
+		value = value + "%"
+	case "suffix":
+		value = "%" + value
+	case "contains":
+		value = "%" + value + "%"
+	default:
+		return "", fmt.Errorf("invalid like position %q, expected prefix, suffix or contains", position)
+	}
+	return fr.bind(value) + ` ESCAPE '\'`, nil
+}
+
+// orderBy builds an ORDER BY clause from the comma-separated list at key, only
+// accepting columns present in the comma-separated allowed list. Each item is
+// a column optionally prefixed by "-" for DESC or followed by ":asc"/":desc",
+// and optionally ":nullsfirst"/":nullslast", e.g. "name,-created_at:nullslast".
+// It returns an empty string when the key is not set.
+func (fr *FuncRegistry) orderBy(key, allowed string) (string, error) {
+	var items []string
+	switch v := fr.TemplateData[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		if v == "" {
+			return "", nil
 		}
-		return fmt.Sprintf("(%s)", strings.Join(ph, ","))
+		items = strings.Split(v, ",")
+	case []string:
+		items = v
+	default:
+		return "", fmt.Errorf("invalid order by value %v", v)
 	}
-	fr.Args = append(fr.Args, fr.TemplateData[key])
-	fr.next++
-	return fmt.Sprintf("($%d)", fr.next)
+	allowedCols := make(map[string]bool)
+	for _, c := range strings.Split(allowed, ",") {
+		allowedCols[strings.TrimSpace(c)] = true
+	}
+	clauses := make([]string, len(items))
+	for i, item := range items {
+		opts := strings.Split(strings.TrimSpace(item), ":")
+		col, direction, nulls := opts[0], "ASC", ""
+		if strings.HasPrefix(col, "-") {
+			col, direction = col[1:], "DESC"
+		}
+		for _, opt := range opts[1:] {
+			switch strings.ToLower(opt) {
+			case "asc":
+				direction = "ASC"
+			case "desc":
+				direction = "DESC"
+			case "nullsfirst":
+				nulls = " NULLS FIRST"
+			case "nullslast":
+				nulls = " NULLS LAST"
+			default:
+				return "", fmt.Errorf("invalid order by option %q", opt)
+			}
+		}
+		if !allowedCols[col] {
+			return "", fmt.Errorf("order by column %q is not allowed", col)
+		}
+		q, err := ident.Quote(col)
+		if err != nil {
+			return "", err
+		}
+		clauses[i] = fmt.Sprintf("%s %s%s", q, direction, nulls)
+	}
+	return "ORDER BY " + strings.Join(clauses, ", "), nil
+}
+
+// KeysetColumn is the column keyset pages are sorted by, it is shared by the
+// keyset template helper and the keyset pagination of the tables endpoint
+type KeysetColumn struct {
+	quoted    string
+	op        string
+	direction string
+}
+
+// Keyset quotes column and reads direction, "asc" (rows after the cursor)
+// or "desc" (rows before it)
+func Keyset(column, direction string) (k KeysetColumn, err error) {
+	k.quoted, err = ident.Quote(column)
+	if err != nil {
+		return
+	}
+	switch strings.ToLower(direction) {
+	case "asc":
+		k.op, k.direction = ">", "ASC"
+	case "desc":
+		k.op, k.direction = "<", "DESC"
+	default:
+		err = fmt.Errorf("invalid keyset direction %q, expected asc or desc", direction)
+	}
+	return
+}
+
+// After returns the condition selecting the rows past the cursor bound to
+// placeholder
+func (k KeysetColumn) After(placeholder string) string {
+	return fmt.Sprintf("%s %s %s", k.quoted, k.op, placeholder)
+}
+
+// OrderBy returns the ORDER BY clause of the keyset pages
+func (k KeysetColumn) OrderBy() string {
+	return fmt.Sprintf("ORDER BY %s %s", k.quoted, k.direction)
+}
+
+// keyset returns a cursor pagination fragment such as
+// WHERE "col" > $1 ORDER BY "col" ASC LIMIT $2, with the cursor read from
+// cursorKey and the page size from limitKey bound as parameters. The size
+// is clamped to MaxPageSize and the WHERE is omitted when no cursor is set,
+// which serves the first page
+func (fr *FuncRegistry) keyset(column, direction, cursorKey, limitKey string) (string, error) {
+	k, err := Keyset(column, direction)
+	if err != nil {
+		return "", err
+	}
+	_, limit, err := PageBounds("1", fmt.Sprint(fr.TemplateData[limitKey]))
+	if err != nil {
+		return "", fmt.Errorf("invalid keyset limit %v", fr.TemplateData[limitKey])
+	}
+	var where string
+	if cursor, ok := fr.TemplateData[cursorKey]; ok && cursor != "" {
+		where = "WHERE " + k.After(fr.bind(cursor)) + " "
+	}
+	return fmt.Sprintf("%s%s LIMIT %s", where, k.OrderBy(), fr.bind(limit)), nil
+}
+
+// coalesce returns COALESCE("column", $n) with defaultValue bound as a
+// parameter, e.g. {{ coalesce "t.discount" 0 }}
+func (fr *FuncRegistry) coalesce(column string, defaultValue interface{}) (string, error) {
+	col, err := ident.Quote(column)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("COALESCE(%s, %s)", col, fr.bind(defaultValue)), nil
 }
 
 // ident validates and safely quotes an identifier (optionally dotted path)
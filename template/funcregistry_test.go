@@ -1,9 +1,13 @@
 package template
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"text/template"
 )
 
 func TestIsSet(t *testing.T) {
@@ -32,6 +36,49 @@ func TestDefaultOrValue(t *testing.T) {
 	if value != "testDefault" {
 		t.Errorf("expected 'testDefault' but got %s", value)
 	}
+	if _, ok := data["testDefaultValue"]; ok || len(data) != 1 {
+		t.Errorf("defaultOrValue must not mutate TemplateData, got %v", data)
+	}
+	if funcs.isSet("testDefaultValue") {
+		t.Error("isSet must stay false after defaultOrValue")
+	}
+}
+
+func TestNestedKeys(t *testing.T) {
+	data := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"status": "active",
+			"range":  map[string]interface{}{"from": "2024-01-01"},
+		},
+		"name":     "flat",
+		"a.b":      "dotted key",
+		"notAMap":  "value",
+		"nilValue": nil,
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+	tests := []struct {
+		key   string
+		isSet bool
+		value interface{}
+	}{
+		{"filter.status", true, "active"},
+		{"filter.range.from", true, "2024-01-01"},
+		{"filter.missing", false, "default"},
+		{"filter.range.from.year", false, "default"},
+		{"missing.status", false, "default"},
+		{"notAMap.status", false, "default"},
+		{"nilValue.status", false, "default"},
+		{"name", true, "flat"},
+		{"a.b", true, "dotted key"},
+	}
+	for _, tt := range tests {
+		if got := funcs.isSet(tt.key); got != tt.isSet {
+			t.Errorf("isSet(%q) = %v, want %v", tt.key, got, tt.isSet)
+		}
+		if got := funcs.defaultOrValue(tt.key, "default"); got != tt.value {
+			t.Errorf("defaultOrValue(%q) = %v, want %v", tt.key, got, tt.value)
+		}
+	}
 }
 
 func TestInFormat(t *testing.T) {
@@ -50,12 +97,565 @@ func TestInFormat(t *testing.T) {
 	}
 }
 
+func TestSqlValCast(t *testing.T) {
+	funcs := &FuncRegistry{TemplateData: map[string]interface{}{"v": "1"}}
+	tests := []struct {
+		pgType  string
+		want    string
+		wantErr bool
+	}{
+		{"int", "$1::int", false},
+		{"TIMESTAMPTZ", "$2::timestamptz", false},
+		{"double precision", "$3::double precision", false},
+		{"uuid[]", "$4::uuid[]", false},
+		{"jsonb", "$5::jsonb", false},
+		{"int; DROP TABLE users", "", true},
+		{"text)--", "", true},
+		{"mytype", "", true},
+		{"", "", true},
+		{"int[][]", "", true},
+	}
+	for _, tt := range tests {
+		got, err := funcs.sqlValCast("v", tt.pgType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sqlValCast(%q) error = %v, wantErr %v", tt.pgType, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqlValCast(%q) = %s, want %s", tt.pgType, got, tt.want)
+		}
+	}
+	if len(funcs.Args) != 5 {
+		t.Errorf("rejected casts must not bind args, got %v", funcs.Args)
+	}
+}
+
+func TestSqlValNamed(t *testing.T) {
+	data := map[string]interface{}{"a": "x", "b": 2}
+	funcs := &FuncRegistry{TemplateData: data}
+
+	got := []string{
+		funcs.sqlValNamed("a"),
+		funcs.sqlVal("b"),
+		funcs.sqlValNamed("a"),
+		funcs.sqlValNamed("b"),
+		funcs.sqlValNamed("b"),
+	}
+	want := []string{"$1", "$2", "$1", "$3", "$3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("placeholders = %v, want %v", got, want)
+	}
+	if fmt.Sprint(funcs.Args) != "[x 2 2]" {
+		t.Errorf("args = %v, want [x 2 2]", funcs.Args)
+	}
+}
+
+func TestSqlList(t *testing.T) {
+	data := map[string]interface{}{
+		"strings": []string{"a", "b"},
+		"ints":    []int{1, 2, 3},
+		"mixed":   []interface{}{"a", 2, true},
+		"empty":   []int{},
+		"bytes":   []byte("raw"),
+		"single":  42,
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+
+	tests := []struct {
+		key  string
+		want string
+		args []interface{}
+	}{
+		{"strings", "($1,$2)", []interface{}{"a", "b"}},
+		{"ints", "($3,$4,$5)", []interface{}{1, 2, 3}},
+		{"mixed", "($6,$7,$8)", []interface{}{"a", 2, true}},
+		{"empty", "()", nil},
+		{"bytes", "($9)", []interface{}{[]byte("raw")}},
+		{"single", "($10)", []interface{}{42}},
+		{"missing", "($11)", []interface{}{nil}},
+	}
+	for _, tt := range tests {
+		before := len(funcs.Args)
+		got := funcs.sqlList(tt.key)
+		if got != tt.want {
+			t.Errorf("sqlList(%q) = %s, want %s", tt.key, got, tt.want)
+		}
+		args := funcs.Args[before:]
+		if fmt.Sprint(args) != fmt.Sprint(tt.args) || len(args) != len(tt.args) {
+			t.Errorf("sqlList(%q) args = %v, want %v", tt.key, args, tt.args)
+		}
+	}
+}
+
+func TestSqlArray(t *testing.T) {
+	data := map[string]interface{}{
+		"strings": []string{"a", "b,c", `d"`},
+		"ints":    []int{1, 2, 3},
+		"single":  "x",
+		"scalar":  7,
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+
+	tests := []struct {
+		key  string
+		want string
+		sql  string
+	}{
+		{"strings", "$1", `{"a","b,c","d\""}`},
+		{"ints", "$2", "{1,2,3}"},
+		{"single", "$3", `{"x"}`},
+	}
+	for _, tt := range tests {
+		got, err := funcs.sqlArray(tt.key)
+		if err != nil {
+			t.Errorf("sqlArray(%q) unexpected error %v", tt.key, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqlArray(%q) = %s, want %s", tt.key, got, tt.want)
+		}
+		valuer, ok := funcs.Args[len(funcs.Args)-1].(driver.Valuer)
+		if !ok {
+			t.Errorf("sqlArray(%q) arg is not a driver.Valuer", tt.key)
+			continue
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			t.Errorf("sqlArray(%q) Value() error %v", tt.key, err)
+			continue
+		}
+		if fmt.Sprintf("%s", value) != tt.sql {
+			t.Errorf("sqlArray(%q) value = %s, want %s", tt.key, value, tt.sql)
+		}
+	}
+
+	for _, key := range []string{"scalar", "missing"} {
+		if _, err := funcs.sqlArray(key); err == nil {
+			t.Errorf("sqlArray(%q) expected error", key)
+		}
+	}
+	if len(funcs.Args) != 3 {
+		t.Errorf("expected 3 args, got %v", funcs.Args)
+	}
+}
+
+func TestSqlBetween(t *testing.T) {
+	data := map[string]interface{}{
+		"strings": []string{"2024-01-01", "2024-12-31"},
+		"ints":    [2]int{1, 10},
+		"mixed":   []interface{}{1.5, "9"},
+		"three":   []int{1, 2, 3},
+		"one":     []string{"1"},
+		"scalar":  "1",
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+
+	tests := []struct {
+		key  string
+		want string
+		args string
+	}{
+		{"strings", "BETWEEN $1 AND $2", "[2024-01-01 2024-12-31]"},
+		{"ints", "BETWEEN $3 AND $4", "[1 10]"},
+		{"mixed", "BETWEEN $5 AND $6", "[1.5 9]"},
+	}
+	for _, tt := range tests {
+		before := len(funcs.Args)
+		got, err := funcs.sqlBetween(tt.key)
+		if err != nil {
+			t.Errorf("sqlBetween(%q) unexpected error %v", tt.key, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqlBetween(%q) = %s, want %s", tt.key, got, tt.want)
+		}
+		if args := fmt.Sprint(funcs.Args[before:]); args != tt.args {
+			t.Errorf("sqlBetween(%q) args = %s, want %s", tt.key, args, tt.args)
+		}
+	}
+
+	for _, key := range []string{"three", "one", "scalar", "missing"} {
+		if _, err := funcs.sqlBetween(key); err == nil {
+			t.Errorf("sqlBetween(%q) expected error", key)
+		}
+	}
+	if len(funcs.Args) != 6 {
+		t.Errorf("invalid values must not bind args, got %v", funcs.Args)
+	}
+}
+
+func TestBoolVal(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"true", true, false},
+		{"TRUE", true, false},
+		{"1", true, false},
+		{"false", false, false},
+		{"0", false, false},
+		{true, true, false},
+		{0, false, false},
+		{"yes", nil, true},
+		{"", nil, true},
+		{"true; DROP TABLE x", nil, true},
+		{nil, nil, true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{TemplateData: map[string]interface{}{"flag": tt.value}}
+		got, err := funcs.boolVal("flag")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("boolVal(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			if len(funcs.Args) != 0 {
+				t.Errorf("boolVal(%v) must not bind args on error", tt.value)
+			}
+			continue
+		}
+		if got != "$1" || funcs.Args[0] != tt.want {
+			t.Errorf("boolVal(%v) = %s %v, want $1 %v", tt.value, got, funcs.Args, tt.want)
+		}
+	}
+}
+
+func TestIntVal(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    int64
+		wantErr bool
+	}{
+		{"42", 42, false},
+		{"-7", -7, false},
+		{5, 5, false},
+		{"4.2", 0, true},
+		{"1e3", 0, true},
+		{"abc", 0, true},
+		{"1 OR 1=1", 0, true},
+		{"", 0, true},
+		{nil, 0, true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{TemplateData: map[string]interface{}{"n": tt.value}}
+		got, err := funcs.intVal("n")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("intVal(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got != "$1" || funcs.Args[0] != tt.want {
+			t.Errorf("intVal(%v) = %s %v, want $1 %v", tt.value, got, funcs.Args, tt.want)
+		}
+	}
+}
+
+func TestSqlIn(t *testing.T) {
+	data := make(map[string]interface{})
+	data["test"] = []string{"test1", "test2", "')); DROP TABLE users; --"}
+	funcs := &FuncRegistry{TemplateData: data}
+	query := funcs.sqlIn("test")
+	if query != "($1,$2,$3)" {
+		t.Errorf("expected ($1,$2,$3), but got %s", query)
+	}
+	if len(funcs.Args) != 3 || funcs.Args[2] != "')); DROP TABLE users; --" {
+		t.Errorf("unexpected args %v", funcs.Args)
+	}
+
+	data["single"] = "test1"
+	query = funcs.sqlIn("single")
+	if query != "($4)" {
+		t.Errorf("expected ($4), but got %s", query)
+	}
+	if len(funcs.Args) != 4 || funcs.Args[3] != "test1" {
+		t.Errorf("unexpected args %v", funcs.Args)
+	}
+}
+
+func TestSqlLike(t *testing.T) {
+	data := map[string]interface{}{
+		"q":      "jo",
+		"wild":   `50%_off\`,
+		"inject": "x' OR '1'='1",
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+
+	tests := []struct {
+		key      string
+		position string
+		want     string
+		arg      string
+	}{
+		{"q", "prefix", `$1 ESCAPE '\'`, "jo%"},
+		{"q", "suffix", `$2 ESCAPE '\'`, "%jo"},
+		{"q", "contains", `$3 ESCAPE '\'`, "%jo%"},
+		{"wild", "contains", `$4 ESCAPE '\'`, `%50\%\_off\\%`},
+		{"inject", "prefix", `$5 ESCAPE '\'`, "x' OR '1'='1%"},
+	}
+	for _, tt := range tests {
+		got, err := funcs.sqlLike(tt.key, tt.position)
+		if err != nil {
+			t.Errorf("sqlLike(%q, %q) unexpected error %v", tt.key, tt.position, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqlLike(%q, %q) = %s, want %s", tt.key, tt.position, got, tt.want)
+		}
+		if last := funcs.Args[len(funcs.Args)-1]; last != tt.arg {
+			t.Errorf("sqlLike(%q, %q) arg = %v, want %v", tt.key, tt.position, last, tt.arg)
+		}
+	}
+
+	if _, err := funcs.sqlLike("q", "middle"); err == nil {
+		t.Error("expected error for invalid position")
+	}
+	if len(funcs.Args) != 5 {
+		t.Errorf("invalid position must not bind args, got %v", funcs.Args)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	allowed := "id, name,created_at"
+	tests := []struct {
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{nil, "", false},
+		{"", "", false},
+		{"name", `ORDER BY "name" ASC`, false},
+		{"-created_at", `ORDER BY "created_at" DESC`, false},
+		{"name:desc,id:asc", `ORDER BY "name" DESC, "id" ASC`, false},
+		{"name:DESC:nullslast", `ORDER BY "name" DESC NULLS LAST`, false},
+		{"-created_at:nullsfirst, id", `ORDER BY "created_at" DESC NULLS FIRST, "id" ASC`, false},
+		{[]string{"id", "-name"}, `ORDER BY "id" ASC, "name" DESC`, false},
+		{"password", "", true},
+		{"name:sideways", "", true},
+		{"name; DROP TABLE users", "", true},
+		{"name,", "", true},
+		{42, "", true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{TemplateData: map[string]interface{}{"order": tt.value}}
+		got, err := funcs.orderBy("order", allowed)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("orderBy(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("orderBy(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	// allowed names are still validated as identifiers
+	funcs := &FuncRegistry{TemplateData: map[string]interface{}{"order": "bad-col"}}
+	if _, err := funcs.orderBy("order", "bad-col"); err == nil {
+		t.Error("expected invalid identifier error")
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		data      map[string]interface{}
+		column    string
+		direction string
+		want      string
+		args      []interface{}
+		wantErr   bool
+	}{
+		{map[string]interface{}{"after": "10", "size": "20"}, "id", "asc", `WHERE "id" > $1 ORDER BY "id" ASC LIMIT $2`, []interface{}{"10", 20}, false},
+		{map[string]interface{}{"after": "2024-01-01", "size": 5}, "t.created_at", "DESC", `WHERE "t"."created_at" < $1 ORDER BY "t"."created_at" DESC LIMIT $2`, []interface{}{"2024-01-01", 5}, false},
+		{map[string]interface{}{"size": "20"}, "id", "asc", `ORDER BY "id" ASC LIMIT $1`, []interface{}{20}, false},
+		{map[string]interface{}{"after": "", "size": "20"}, "id", "asc", `ORDER BY "id" ASC LIMIT $1`, []interface{}{20}, false},
+		{map[string]interface{}{"after": "1", "size": "20"}, "id;DROP TABLE x", "asc", "", nil, true},
+		{map[string]interface{}{"after": "1", "size": "20"}, "id", "up", "", nil, true},
+		{map[string]interface{}{"after": "1", "size": "0"}, "id", "asc", "", nil, true},
+		{map[string]interface{}{"after": "1", "size": "ten"}, "id", "asc", "", nil, true},
+		{map[string]interface{}{"after": "1"}, "id", "asc", "", nil, true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{TemplateData: tt.data}
+		got, err := funcs.keyset(tt.column, tt.direction, "after", "size")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("keyset(%q, %q) error = %v, wantErr %v", tt.column, tt.direction, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("keyset(%q, %q) = %s, want %s", tt.column, tt.direction, got, tt.want)
+		}
+		if fmt.Sprint(funcs.Args) != fmt.Sprint(tt.args) {
+			t.Errorf("keyset(%q, %q) args = %v, want %v", tt.column, tt.direction, funcs.Args, tt.args)
+		}
+	}
+}
+
+func TestKeysetMaxPageSize(t *testing.T) {
+	defer func(max int) { MaxPageSize = max }(MaxPageSize)
+	MaxPageSize = 50
+
+	funcs := &FuncRegistry{TemplateData: map[string]interface{}{"size": "1000"}}
+	got, err := funcs.keyset("id", "asc", "after", "size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `ORDER BY "id" ASC LIMIT $1` || fmt.Sprint(funcs.Args) != "[50]" {
+		t.Errorf("keyset = %s %v, want the limit clamped to 50", got, funcs.Args)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		column       string
+		defaultValue interface{}
+		want         string
+		args         []interface{}
+		wantErr      bool
+	}{
+		{"discount", 0, `COALESCE("discount", $1)`, []interface{}{0}, false},
+		{"t.nickname", "anonymous", `COALESCE("t"."nickname", $1)`, []interface{}{"anonymous"}, false},
+		{"name", "'); DROP TABLE x; --", `COALESCE("name", $1)`, []interface{}{"'); DROP TABLE x; --"}, false},
+		{`name", 1) --`, 0, "", nil, true},
+		{"", 0, "", nil, true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{}
+		got, err := funcs.coalesce(tt.column, tt.defaultValue)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("coalesce(%q) error = %v, wantErr %v", tt.column, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("coalesce(%q) = %s, want %s", tt.column, got, tt.want)
+		}
+		if fmt.Sprint(funcs.Args) != fmt.Sprint(tt.args) {
+			t.Errorf("coalesce(%q) args = %v, want %v", tt.column, funcs.Args, tt.args)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("REPORT_SCHEMA", "reports")
+	t.Setenv("PREST_PG_PASS", "s3cr3t")
+	funcs := &FuncRegistry{EnvAllowList: []string{"REPORT_SCHEMA", "REPORT_REGION"}}
+	tests := []struct {
+		name    string
+		def     []string
+		want    string
+		wantErr bool
+	}{
+		{"REPORT_SCHEMA", nil, "reports", false},
+		{"REPORT_SCHEMA", []string{"public"}, "reports", false},
+		{"REPORT_REGION", nil, "", false},
+		{"REPORT_REGION", []string{"eu"}, "eu", false},
+		{"PREST_PG_PASS", nil, "", false},
+		{"PREST_PG_PASS", []string{"none"}, "none", false},
+		{"REPORT_SCHEMA", []string{"a", "b"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := funcs.env(tt.name, tt.def...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("env(%q, %v) error = %v, wantErr %v", tt.name, tt.def, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("env(%q, %v) = %q, want %q", tt.name, tt.def, got, tt.want)
+		}
+	}
+	if len(funcs.Args) != 0 {
+		t.Errorf("env must not bind arguments, got %v", funcs.Args)
+	}
+}
+
+func TestFmtTime(t *testing.T) {
+	data := map[string]interface{}{
+		// 2024-03-10 06:59:59 UTC, one second before New York springs forward
+		"beforeDST":  "2024-03-10T06:59:59Z",
+		"afterDST":   "2024-03-10T07:00:00Z",
+		"epoch":      int64(1710053999),
+		"epochStr":   "1710054000",
+		"epochFloat": float64(1710054000),
+		"offset":     "2024-10-27T02:30:00+02:00",
+		"invalid":    "yesterday",
+	}
+	layout := "2006-01-02 15:04:05 MST"
+	tests := []struct {
+		key     string
+		tz      string
+		want    string
+		wantErr bool
+	}{
+		{"beforeDST", "America/New_York", "2024-03-10 01:59:59 EST", false},
+		{"afterDST", "America/New_York", "2024-03-10 03:00:00 EDT", false},
+		{"epoch", "America/New_York", "2024-03-10 01:59:59 EST", false},
+		{"epochStr", "America/New_York", "2024-03-10 03:00:00 EDT", false},
+		{"epochFloat", "UTC", "2024-03-10 07:00:00 UTC", false},
+		{"offset", "Europe/Paris", "2024-10-27 02:30:00 CEST", false},
+		{"invalid", "UTC", "", true},
+		{"missing", "UTC", "", true},
+		{"beforeDST", "Mars/Olympus_Mons", "", true},
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+	for _, tt := range tests {
+		got, err := funcs.fmtTime(tt.key, layout, tt.tz)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("fmtTime(%q, %q) error = %v, wantErr %v", tt.key, tt.tz, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("fmtTime(%q, %q) = %q, want %q", tt.key, tt.tz, got, tt.want)
+		}
+	}
+	if len(funcs.Args) != 0 {
+		t.Errorf("fmtTime must not bind arguments, got %v", funcs.Args)
+	}
+}
+
+func TestStringHelpers(t *testing.T) {
+	data := map[string]interface{}{
+		"name":   "  Gopher Go  ",
+		"status": "ACTIVE",
+		"num":    42,
+	}
+	funcs := &FuncRegistry{TemplateData: data}
+
+	if got := funcs.upper("name"); got != "  GOPHER GO  " {
+		t.Errorf("upper = %q", got)
+	}
+	if got := funcs.lower("status"); got != "active" {
+		t.Errorf("lower = %q", got)
+	}
+	if got := funcs.trim("name"); got != "Gopher Go" {
+		t.Errorf("trim = %q", got)
+	}
+	if got := funcs.replace("name", "Go", "Rust"); got != "  Rustpher Rust  " {
+		t.Errorf("replace = %q", got)
+	}
+	if got := funcs.upper("num"); got != "42" {
+		t.Errorf("upper on non-string = %q", got)
+	}
+	if got := funcs.lower("missing"); got != "" {
+		t.Errorf("lower on missing key = %q", got)
+	}
+	if len(funcs.Args) != 0 {
+		t.Errorf("string helpers must not bind args, got %v", funcs.Args)
+	}
+	if data["name"] != "  Gopher Go  " {
+		t.Errorf("string helpers must not mutate TemplateData, got %q", data["name"])
+	}
+}
+
 func TestSplit(t *testing.T) {
 	data := make(map[string]interface{})
 	list3itens := "test1,test2,test3"
 	data["list3itens"] = list3itens
 	funcs := &FuncRegistry{TemplateData: data}
-	query := funcs.split(list3itens, ",")
+	query, err := funcs.split(list3itens, ",")
+	if err != nil {
+		t.Fatal(err)
+	}
 	s := strings.Split(list3itens, ",")
 	if len(query) != 3 {
 		t.Errorf("expected (3), but got %d", len(query))
@@ -65,6 +665,47 @@ func TestSplit(t *testing.T) {
 	}
 }
 
+func TestSplitN(t *testing.T) {
+	tests := []struct {
+		orig    string
+		count   []int
+		want    []string
+		wantErr bool
+	}{
+		{"a/b/c", []int{2}, []string{"a", "b/c"}, false},
+		{"a/b/c", []int{1}, []string{"a/b/c"}, false},
+		{"a/b/c", []int{5}, []string{"a", "b", "c"}, false},
+		{"a/b/c", []int{-1}, []string{"a", "b", "c"}, false},
+		{"a/b/c", []int{0}, nil, false},
+		{"a/b/c", []int{1, 2}, nil, true},
+	}
+	funcs := &FuncRegistry{}
+	for _, tt := range tests {
+		got, err := funcs.split(tt.orig, "/", tt.count...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("split(%q, %v) error = %v, wantErr %v", tt.orig, tt.count, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split(%q, %v) = %q, want %q", tt.orig, tt.count, got, tt.want)
+		}
+	}
+
+	for tpl, want := range map[string]string{
+		`{{ range split "a/b/c" "/" }}[{{ . }}]{{ end }}`:   "[a][b][c]",
+		`{{ range split "a/b/c" "/" 2 }}[{{ . }}]{{ end }}`: "[a][b/c]",
+	} {
+		var out strings.Builder
+		err := template.Must(template.New("split").Funcs(funcs.RegistryAllFuncs()).Parse(tpl)).Execute(&out, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want {
+			t.Errorf("%s = %s, want %s", tpl, out.String(), want)
+		}
+	}
+}
+
 func TestRegistryAllFuncs(t *testing.T) {
 	data := make(map[string]interface{})
 	data["test"] = "testValue"
@@ -87,6 +728,10 @@ func TestRegistryAllFuncs(t *testing.T) {
 	if !ok {
 		t.Error("func `split` is not registred")
 	}
+	_, ok = fmap["sqlIn"]
+	if !ok {
+		t.Error("func `sqlIn` is not registred")
+	}
 }
 
 func TestUnEscape(t *testing.T) {
@@ -107,24 +752,150 @@ func TestLimitOffset(t *testing.T) {
 	data["_page"] = pageNumber
 	data["_page_size"] = pageSize
 	funcs := &FuncRegistry{TemplateData: data}
-	value := funcs.limitOffset(fmt.Sprint(pageNumber), fmt.Sprint(pageSize))
+	value, err := funcs.limitOffset(fmt.Sprint(pageNumber), fmt.Sprint(pageSize))
 	expected := fmt.Sprintf("LIMIT %d OFFSET(%d - 1) * %d", pageSize, pageNumber, pageSize)
-	if value != expected {
-		t.Errorf("expected '%s', bug got %s", expected, value)
+	if err != nil || value != expected {
+		t.Errorf("expected '%s', bug got %s (%v)", expected, value, err)
 	}
 
-	value = funcs.limitOffset("0", fmt.Sprint(pageSize))
-	if value != expected {
-		t.Errorf("expected '%s', bug got %s", expected, value)
+	value, err = funcs.limitOffset("0", fmt.Sprint(pageSize))
+	if err != nil || value != expected {
+		t.Errorf("expected '%s', bug got %s (%v)", expected, value, err)
+	}
+
+	for _, page := range [][2]string{{"a", "10"}, {"1", "a"}, {"1", "0"}, {"1", "-5"}} {
+		if value, err = funcs.limitOffset(page[0], page[1]); err == nil {
+			t.Errorf("limitOffset(%q, %q) expected error, got %s", page[0], page[1], value)
+		}
+	}
+}
+
+func TestLimitOffsetArgs(t *testing.T) {
+	tests := []struct {
+		pageNumber string
+		pageSize   string
+		want       string
+		args       []interface{}
+		wantErr    bool
+	}{
+		{"1", "10", "LIMIT $1 OFFSET $2", []interface{}{10, 0}, false},
+		{"3", "20", "LIMIT $1 OFFSET $2", []interface{}{20, 40}, false},
+		{"0", "10", "LIMIT $1 OFFSET $2", []interface{}{10, 0}, false},
+		{"-2", "10", "LIMIT $1 OFFSET $2", []interface{}{10, 0}, false},
+		{"1", "0", "", nil, true},
+		{"1", "-5", "", nil, true},
+		{"a", "10", "", nil, true},
+		{"1", "a", "", nil, true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{TemplateData: map[string]interface{}{}}
+		got, err := funcs.limitOffsetArgs(tt.pageNumber, tt.pageSize)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("limitOffsetArgs(%q, %q) error = %v, wantErr %v", tt.pageNumber, tt.pageSize, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("limitOffsetArgs(%q, %q) = %s, want %s", tt.pageNumber, tt.pageSize, got, tt.want)
+		}
+		if fmt.Sprint(funcs.Args) != fmt.Sprint(tt.args) {
+			t.Errorf("limitOffsetArgs(%q, %q) args = %v, want %v", tt.pageNumber, tt.pageSize, funcs.Args, tt.args)
+		}
+	}
+}
+
+func TestLimitOffsetBounds(t *testing.T) {
+	t.Cleanup(func() { MaxPageSize = 0 })
+
+	for _, size := range []string{"0", "-1"} {
+		if _, err := LimitOffset("1", size); err == nil {
+			t.Errorf("LimitOffset(\"1\", %q) expected error", size)
+		}
 	}
 
-	value = funcs.limitOffset("a", fmt.Sprint(pageSize))
-	if value != "" {
-		t.Errorf("expected '%s', bug got %s", "", value)
+	value, err := LimitOffset("2", "1000000")
+	if err != nil || value != "LIMIT 1000000 OFFSET(2 - 1) * 1000000" {
+		t.Errorf("without max page size got %q, %v", value, err)
 	}
 
-	value = funcs.limitOffset(fmt.Sprint(pageNumber), "a")
-	if value != "" {
-		t.Errorf("expected '%s', bug got %s", "", value)
+	MaxPageSize = 100
+	value, err = LimitOffset("2", "1000000")
+	if err != nil || value != "LIMIT 100 OFFSET(2 - 1) * 100" {
+		t.Errorf("expected size clamped to 100, got %q, %v", value, err)
+	}
+	value, err = LimitOffset("2", "50")
+	if err != nil || value != "LIMIT 50 OFFSET(2 - 1) * 50" {
+		t.Errorf("expected size below max unchanged, got %q, %v", value, err)
+	}
+
+	funcs := &FuncRegistry{TemplateData: map[string]interface{}{}}
+	if _, err = funcs.limitOffsetArgs("3", "500"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(funcs.Args) != "[100 200]" {
+		t.Errorf("expected clamped args [100 200], got %v", funcs.Args)
+	}
+}
+
+func TestIdent(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"users", `"users"`, false},
+		{"public.users", `"public"."users"`, false},
+		{"café", `"café"`, false},
+		{strings.Repeat("a", 63), `"` + strings.Repeat("a", 63) + `"`, false},
+		{strings.Repeat("a", 64), "", true},
+		{`users"; DROP TABLE x; --`, "", true},
+		{"foo..bar", "", true},
+		{"", "", true},
+		{42, "", true},
+		{nil, "", true},
+	}
+	for _, tt := range tests {
+		funcs := &FuncRegistry{TemplateData: map[string]interface{}{"table": tt.value}}
+		got, err := funcs.ident("table")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ident(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ident(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestResetBetweenRenders(t *testing.T) {
+	funcs := &FuncRegistry{TemplateData: map[string]interface{}{"id": "1", "name": "a"}}
+	tpl := template.Must(template.New("q").Funcs(funcs.RegistryAllFuncs()).
+		Parse(`SELECT * FROM t WHERE id = {{sqlValNamed "id"}} AND name = {{sqlVal "name"}}`))
+
+	var first bytes.Buffer
+	if err := tpl.Execute(&first, funcs.TemplateData); err != nil {
+		t.Fatal(err)
+	}
+	args := funcs.BoundArgs()
+	if fmt.Sprint(args) != "[1 a]" {
+		t.Errorf("first render args = %v", args)
+	}
+
+	funcs.Reset()
+	if len(funcs.BoundArgs()) != 0 {
+		t.Errorf("expected no args after Reset, got %v", funcs.BoundArgs())
+	}
+	funcs.TemplateData["id"] = "2"
+	var second bytes.Buffer
+	if err := tpl.Execute(&second, funcs.TemplateData); err != nil {
+		t.Fatal(err)
+	}
+	if second.String() != first.String() {
+		t.Errorf("placeholders differ between renders: %q vs %q", first.String(), second.String())
+	}
+	if fmt.Sprint(funcs.BoundArgs()) != "[2 a]" {
+		t.Errorf("second render args = %v", funcs.BoundArgs())
+	}
+	if fmt.Sprint(args) != "[1 a]" {
+		t.Errorf("BoundArgs copy changed after Reset: %v", args)
 	}
 }
@@ -0,0 +1,148 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Store keeps parsed SQL templates in memory so they are not parsed again
+// on every request
+type Store struct {
+	// Debug re-parses a template on Render when its file has changed on disk
+	Debug bool
+	// EnvAllowList are the environment variables templates may read with env
+	EnvAllowList []string
+
+	dir       string
+	mu        sync.RWMutex
+	templates map[string]*cachedTemplate
+}
+
+type cachedTemplate struct {
+	tpl     *template.Template
+	modTime time.Time
+}
+
+// NewStore returns an empty store for the templates under dir, each one is
+// parsed on its first Render
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, templates: make(map[string]*cachedTemplate)}
+}
+
+// LoadDir parses every *.sql and *.tmpl file under path, templates are named
+// by their slash separated path relative to it, e.g. "fulltable/get_all.read.sql"
+func LoadDir(path string) (*Store, error) {
+	s := NewStore(path)
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if ext := filepath.Ext(file); ext != ".sql" && ext != ".tmpl" {
+			return nil
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		ct, err := parseFile(filepath.ToSlash(rel), file)
+		if err != nil {
+			return err
+		}
+		s.templates[filepath.ToSlash(rel)] = ct
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load templates: %w", err)
+	}
+	return s, nil
+}
+
+func parseFile(name, file string) (*cachedTemplate, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// funcs are bound to a registry per render, these only satisfy the parser
+	fr := &FuncRegistry{}
+	tpl, err := template.New(name).Funcs(fr.RegistryAllFuncs()).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", name, err)
+	}
+	return &cachedTemplate{tpl: tpl, modTime: info.ModTime()}, nil
+}
+
+// Names returns the names of the loaded templates
+func (s *Store) Names() (names []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	return
+}
+
+// Render executes the named template with data and returns the SQL along
+// with the arguments bound by the SQL helpers
+func (s *Store) Render(name string, data map[string]interface{}) (sql string, args []interface{}, err error) {
+	ct, err := s.get(name)
+	if err != nil {
+		return
+	}
+	tpl, err := ct.tpl.Clone()
+	if err != nil {
+		return
+	}
+	funcs := &FuncRegistry{TemplateData: data, EnvAllowList: s.EnvAllowList}
+	var buff bytes.Buffer
+	err = tpl.Funcs(funcs.RegistryAllFuncs()).Execute(&buff, funcs.TemplateData)
+	if err != nil {
+		err = fmt.Errorf("could not execute template %v", err)
+		return
+	}
+	sql = buff.String()
+	args = funcs.Args
+	return
+}
+
+// get returns the parsed template, parsing it when it is not loaded yet or,
+// in Debug, when its file has changed on disk
+func (s *Store) get(name string) (*cachedTemplate, error) {
+	s.mu.RLock()
+	ct, ok := s.templates[name]
+	s.mu.RUnlock()
+	if ok && !s.Debug {
+		return ct, nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	file := filepath.Join(s.dir, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("template %s not found: %w", name, err)
+	}
+	if ok && info.ModTime().Equal(ct.modTime) {
+		return ct, nil
+	}
+	ct, err = parseFile(name, file)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.templates[name] = ct
+	s.mu.Unlock()
+	return ct, nil
+}
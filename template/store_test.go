@@ -0,0 +1,122 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "users", "get.read.sql"), `SELECT * FROM users WHERE id = {{sqlVal "id"}}`)
+	writeFile(t, filepath.Join(dir, "list.tmpl"), `SELECT * FROM t WHERE name IN {{sqlList "names"}}`)
+	writeFile(t, filepath.Join(dir, "README.md"), `{{ not a template`)
+
+	store, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := store.Names()
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[list.tmpl users/get.read.sql]" {
+		t.Errorf("unexpected templates %v", names)
+	}
+
+	sql, args, err := store.Render("users/get.read.sql", map[string]interface{}{"id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != `SELECT * FROM users WHERE id = $1` || fmt.Sprint(args) != "[1]" {
+		t.Errorf("got %q %v", sql, args)
+	}
+
+	// renders don't share args
+	sql, args, err = store.Render("list.tmpl", map[string]interface{}{"names": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != `SELECT * FROM t WHERE name IN ($1,$2)` || fmt.Sprint(args) != "[a b]" {
+		t.Errorf("got %q %v", sql, args)
+	}
+
+	if _, _, err = store.Render("missing.sql", nil); err == nil {
+		t.Error("expected error for unknown template")
+	}
+}
+
+func TestLoadDirParseError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bad.sql"), `SELECT {{ .x`)
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, err := LoadDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing dir")
+	}
+}
+
+func TestStoreDebugReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "q.sql")
+	writeFile(t, file, `SELECT 1`)
+
+	store, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, file, `SELECT 2`)
+	future := time.Now().Add(time.Minute)
+	if err = os.Chtimes(file, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	sql, _, err := store.Render("q.sql", nil)
+	if err != nil || sql != "SELECT 1" {
+		t.Errorf("without debug expected cached template, got %q %v", sql, err)
+	}
+
+	store.Debug = true
+	sql, _, err = store.Render("q.sql", nil)
+	if err != nil || sql != "SELECT 2" {
+		t.Errorf("with debug expected reloaded template, got %q %v", sql, err)
+	}
+}
+
+func TestNewStoreLazyLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "q.sql"), `SELECT {{sqlVal "id"}}`)
+	writeFile(t, filepath.Join(dir, "broken.sql"), `SELECT {{ .x`)
+	writeFile(t, filepath.Join(filepath.Dir(dir), "outside.sql"), `SELECT 1`)
+
+	store := NewStore(dir)
+	if len(store.Names()) != 0 {
+		t.Errorf("expected no template before the first render, got %v", store.Names())
+	}
+	sql, args, err := store.Render("q.sql", map[string]interface{}{"id": 1})
+	if err != nil || sql != "SELECT $1" || fmt.Sprint(args) != "[1]" {
+		t.Errorf("got %q %v %v", sql, args, err)
+	}
+	if fmt.Sprint(store.Names()) != "[q.sql]" {
+		t.Errorf("expected q.sql cached, got %v", store.Names())
+	}
+
+	if _, _, err = store.Render("broken.sql", nil); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, _, err = store.Render("../outside.sql", nil); err == nil {
+		t.Error("expected error for a template outside the store")
+	}
+}
@@ -19,6 +19,8 @@ CREATE TABLE testarray(id serial, data character varying(250)[]);
 CREATE TABLE test_empty_table(id serial, data character varying(250)[]);
 CREATE TABLE test_group_by_table(id serial, name text, age integer, salary int);
 CREATE TABLE prest_users(id serial, username text, password text);
+CREATE TABLE test_soft_delete(id serial, name text, deleted_at timestamptz);
+CREATE TABLE test_skip_insert(id serial, name text);
 
 -- Inserts
 INSERT INTO test (name) VALUES ('prest tester');
@@ -42,8 +44,13 @@ INSERT INTO test_group_by_table(name, age, salary) VALUES('maria', 19, 3999);
 INSERT INTO test_group_by_table(name, age, salary) VALUES('gopher', 20, 100);
 INSERT INTO test_group_by_table(name, age, salary) VALUES('guitarra humana', 19, 3998);
 INSERT INTO prest_users(username, password) VALUES('test@postgres.rest', 'e10adc3949ba59abbe56e057f20f883e');
+INSERT INTO test_soft_delete(name) VALUES('kept'), ('removed');
 
 -- Views
 CREATE TABLE table_to_view(id serial, name text, celphone text);
 INSERT INTO table_to_view (name, celphone) VALUES ('gopher', '8888888');
 CREATE VIEW view_test AS SELECT name AS player from table_to_view;
+
+-- Triggers
+CREATE FUNCTION skip_insert() RETURNS trigger AS $$ BEGIN RETURN NULL; END; $$ LANGUAGE plpgsql;
+CREATE TRIGGER skip_insert BEFORE INSERT ON test_skip_insert FOR EACH ROW EXECUTE FUNCTION skip_insert();